@@ -33,10 +33,21 @@ func main() {
 	// Log version and build information
 	cfg.Logger.Info("Starting iperf3 exporter")
 
-	// Check if iperf3 exists
-	if err := iperf.CheckIperf3Exists(); err != nil {
-		cfg.Logger.Error("iperf3 command not found, please install iperf3", "err", err)
-		os.Exit(1)
+	// Check that every iperf3 binary in use exists, none is needed when every test uses the native runner
+	if binaries := cfg.Iperf3Binaries(); len(binaries) > 0 {
+		if err := iperf.CheckIperf3Exists(binaries...); err != nil {
+			cfg.Logger.Error("iperf3 command not found, please install iperf3", "err", err)
+			os.Exit(1)
+		}
+	} else if err := iperf.CheckIperf3Exists(); err != nil {
+		cfg.Logger.Info("iperf3 command not found, only the native runner is available", "err", err)
+	}
+
+	if binaries := cfg.Iperf2Binaries(); len(binaries) > 0 {
+		if err := iperf.CheckIperf2Exists(binaries...); err != nil {
+			cfg.Logger.Error("iperf2 command not found, please install iperf2", "err", err)
+			os.Exit(1)
+		}
 	}
 
 	// Create and start HTTP server
@@ -0,0 +1,284 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive stores the raw output of iperf3 runs on disk for later investigation.
+package archive
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileSuffix is the suffix of the archived run files, named after the run ID.
+const fileSuffix = ".json"
+
+// idPattern matches the run IDs generated by NewID.
+var idPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}\.[0-9]{3}Z-[0-9a-f]{8}$`)
+
+// ErrNotFound is returned when a run is not in the archive.
+var ErrNotFound = errors.New("run not found")
+
+// Run is the archived record of a single iperf3 run. Name is empty for /probe requests.
+type Run struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Target    string    `json:"target"`
+	Port      int       `json:"port"`
+	Protocol  string    `json:"protocol"`
+	Reverse   bool      `json:"reverse"`
+	Timestamp time.Time `json:"timestamp"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	Command   []string  `json:"command"`
+	ExitCode  int       `json:"exit_code"`
+	// Output is the JSON document printed by iperf3, OutputText is used instead
+	// when the output is not valid JSON
+	Output     json.RawMessage `json:"output,omitempty"`
+	OutputText string          `json:"output_text,omitempty"`
+	Stderr     string          `json:"stderr,omitempty"`
+}
+
+// Summary describes an archived run without its output.
+type Summary struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Target    string    `json:"target"`
+	Port      int       `json:"port"`
+	Protocol  string    `json:"protocol"`
+	Reverse   bool      `json:"reverse"`
+	Timestamp time.Time `json:"timestamp"`
+	Success   bool      `json:"success"`
+	Size      int64     `json:"size_bytes"`
+}
+
+// Archive is a directory of archived runs with age and size based retention.
+type Archive struct {
+	dir      string
+	maxAge   time.Duration
+	maxBytes int64
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	runs  []Summary // oldest first
+	total int64
+}
+
+// New opens the archive in dir, creating it if needed. Runs older than maxAge are removed,
+// and the oldest runs are removed while the archive is larger than maxBytes. A zero value
+// disables the limit.
+func New(dir string, maxAge time.Duration, maxBytes int64, logger *slog.Logger) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	a := &Archive{
+		dir:      dir,
+		maxAge:   maxAge,
+		maxBytes: maxBytes,
+		logger:   logger,
+	}
+
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune(time.Now())
+
+	return a, nil
+}
+
+// NewID returns a new run ID. IDs sort in the order they were generated, to the millisecond.
+func NewID(now time.Time) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+
+	return now.UTC().Format("20060102T150405.000Z") + "-" + hex.EncodeToString(b[:])
+}
+
+// load builds the index of the runs already in the archive directory.
+func (a *Archive) load() error {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), fileSuffix)
+		if !ok || !idPattern.MatchString(id) {
+			continue
+		}
+
+		data, err := os.ReadFile(a.path(id))
+		if err != nil {
+			a.logger.Warn("Skipping unreadable archived run", "run_id", id, "err", err)
+			continue
+		}
+
+		var summary Summary
+		if err := json.Unmarshal(data, &summary); err != nil {
+			a.logger.Warn("Skipping invalid archived run", "run_id", id, "err", err)
+			continue
+		}
+
+		summary.ID = id
+		summary.Size = int64(len(data))
+
+		a.runs = append(a.runs, summary)
+		a.total += summary.Size
+	}
+
+	// IDs generated within the same millisecond do not sort in the order they were stored
+	sort.Slice(a.runs, func(i, j int) bool {
+		if !a.runs[i].Timestamp.Equal(a.runs[j].Timestamp) {
+			return a.runs[i].Timestamp.Before(a.runs[j].Timestamp)
+		}
+
+		return a.runs[i].ID < a.runs[j].ID
+	})
+
+	return nil
+}
+
+// Store writes run to the archive, assigning it an ID if it has none, and applies the retention.
+func (a *Archive) Store(run Run) (string, error) {
+	now := time.Now()
+
+	if run.ID == "" {
+		run.ID = NewID(now)
+	}
+
+	if run.Timestamp.IsZero() {
+		run.Timestamp = now
+	}
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode run: %w", err)
+	}
+
+	// Write to a temporary file first so that a run is never listed half written
+	tmp, err := os.CreateTemp(a.dir, ".run-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive file: %w", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return "", fmt.Errorf("failed to write archive file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return "", fmt.Errorf("failed to write archive file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), a.path(run.ID)); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return "", fmt.Errorf("failed to write archive file: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.runs = append(a.runs, Summary{
+		ID:        run.ID,
+		Name:      run.Name,
+		Target:    run.Target,
+		Port:      run.Port,
+		Protocol:  run.Protocol,
+		Reverse:   run.Reverse,
+		Timestamp: run.Timestamp,
+		Success:   run.Success,
+		Size:      int64(len(data)),
+	})
+	a.total += int64(len(data))
+
+	a.prune(now)
+
+	return run.ID, nil
+}
+
+// prune removes the runs that are over the retention limits, a.mu must be held.
+func (a *Archive) prune(now time.Time) {
+	for len(a.runs) > 0 {
+		oldest := a.runs[0]
+
+		expired := a.maxAge > 0 && now.Sub(oldest.Timestamp) > a.maxAge
+		oversized := a.maxBytes > 0 && a.total > a.maxBytes
+
+		if !expired && !oversized {
+			return
+		}
+
+		if err := os.Remove(a.path(oldest.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			a.logger.Warn("Failed to remove archived run", "run_id", oldest.ID, "err", err)
+		}
+
+		a.runs = a.runs[1:]
+		a.total -= oldest.Size
+	}
+}
+
+// List returns the archived runs newest first, of the configured target with the given name
+// and of the target host, each filter being skipped when empty.
+func (a *Archive) List(name string, target string) []Summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var runs []Summary
+
+	for i := len(a.runs) - 1; i >= 0; i-- {
+		if (name == "" || a.runs[i].Name == name) && (target == "" || a.runs[i].Target == target) {
+			runs = append(runs, a.runs[i])
+		}
+	}
+
+	return runs
+}
+
+// Open opens the archived run with the given ID, which is the JSON encoding of a Run.
+func (a *Archive) Open(id string) (*os.File, error) {
+	if !idPattern.MatchString(id) {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(a.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return f, err
+}
+
+// path returns the path of the file of a run.
+func (a *Archive) path(id string) string {
+	return filepath.Join(a.dir, id+fileSuffix)
+}
@@ -2,38 +2,120 @@ package collector
 
 import (
 	"sync"
-	
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 )
 
+// DefaultStaleIntervals is the number of target intervals after which a result that
+// was not replaced by a newer one is no longer exported.
+const DefaultStaleIntervals = 3
+
 type MetricsCache struct {
     mu      sync.RWMutex
-    // Map target_name -> metrics
-    storage map[string][]*dto.MetricFamily
+    // Map target_name -> latest finished test
+    results map[string]cachedRun
+    // Map target_name -> metrics of the test that is still running
+    current map[string][]*dto.MetricFamily
+    // Results are dropped after this many intervals of their target, 0 keeps them forever
+    staleIntervals float64
+}
+
+// cachedRun is the latest finished test of a target and the collector that renders it.
+type cachedRun struct {
+    collector *Collector
+    run       TestRun
+    // Age after which the run is dropped, 0 keeps it forever
+    maxAge    time.Duration
 }
 
-func NewMetricsCache() *MetricsCache {
+// NewMetricsCache creates a cache that drops results after staleIntervals intervals of their target.
+func NewMetricsCache(staleIntervals float64) *MetricsCache {
     return &MetricsCache{
-        storage: make(map[string][]*dto.MetricFamily),
+        results:        make(map[string]cachedRun),
+        current:        make(map[string][]*dto.MetricFamily),
+        staleIntervals: staleIntervals,
     }
 }
 
-// Update updates the cache with the latest metrics for a specific target.
-func (mc *MetricsCache) Update(target string, metrics []*dto.MetricFamily) {
+// Update updates the cache with the latest test run of a specific target, rendered by c
+// at scrape time. The metrics of the running test of the target, if any, are dropped.
+func (mc *MetricsCache) Update(target string, c *Collector, run TestRun, interval time.Duration) {
     mc.mu.Lock()
     defer mc.mu.Unlock()
-    mc.storage[target] = metrics
+    mc.results[target] = cachedRun{
+        collector: c,
+        run:       run,
+        maxAge:    time.Duration(mc.staleIntervals * float64(interval)),
+    }
+    delete(mc.current, target)
+}
+
+// UpdateCurrent updates the cache with the metrics of the test that is still running for a specific target.
+func (mc *MetricsCache) UpdateCurrent(target string, metrics []*dto.MetricFamily) {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+    mc.current[target] = metrics
 }
 
 // Gather implements prometheus.Gatherer.
-// It returns all stored metrics from all targets.
+// It returns the metrics of the latest test of every target, with the time the test
+// finished as their timestamp, and the metrics of the tests that are still running.
+// Results that went stale are dropped.
 func (mc *MetricsCache) Gather() ([]*dto.MetricFamily, error) {
-    mc.mu.RLock()
-    defer mc.mu.RUnlock()
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    now := time.Now()
+
+    runs := make([]cachedRun, 0, len(mc.results))
+    for target, cached := range mc.results {
+        if cached.maxAge > 0 && now.Sub(cached.run.Finished) > cached.maxAge {
+            delete(mc.results, target)
+            continue
+        }
+        runs = append(runs, cached)
+    }
+
+    registry := prometheus.NewRegistry()
+    registry.MustRegister(&cachedRunsCollector{runs: runs, now: now})
+
+    allMetrics, err := registry.Gather()
+    if err != nil {
+        return nil, err
+    }
 
-    var allMetrics []*dto.MetricFamily
-    for _, m := range mc.storage {
+    for _, m := range mc.current {
         allMetrics = append(allMetrics, m...)
     }
     return allMetrics, nil
-}
\ No newline at end of file
+}
+
+// cachedRunsCollector renders cached test runs into metrics. It is an unchecked
+// collector since the labels of the targets differ.
+type cachedRunsCollector struct {
+    runs []cachedRun
+    now  time.Time
+}
+
+// Describe implements the prometheus.Collector interface.
+func (cc *cachedRunsCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface.
+func (cc *cachedRunsCollector) Collect(ch chan<- prometheus.Metric) {
+    for _, cached := range cc.runs {
+        metrics := make(chan prometheus.Metric)
+        go func() {
+            defer close(metrics)
+            cached.collector.CollectRun(metrics, cached.run)
+        }()
+
+        // The results were measured when the test finished, not at scrape time
+        for m := range metrics {
+            ch <- prometheus.NewMetricWithTimestamp(cached.run.Finished, m)
+        }
+
+        cached.collector.CollectResultAge(ch, cached.run, cc.now)
+    }
+}
@@ -16,11 +16,17 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/yuvaldekel/iperf3_exporter/internal/archive"
 	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -43,43 +49,152 @@ var (
 			Help: "Errors raised by the iperf3 exporter.",
 		},
 	)
+	Failures = NewFailureCollector()
+	BinaryInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "binary", "info"),
+			Help: "Version of the iperf3 binaries used by the exporter, always 1.",
+		},
+		[]string{"binary", "version"},
+	)
 )
 
 // TargetConfig represents the configuration for a single probe.
 type TargetConfig struct {
-    Target      string          `yaml:"target"      validate:"required,hostname|ip"`
-    Port        int             `yaml:"port"        validate:"required,min=1,max=65535"`
-    Period      time.Duration   `yaml:"period"      validate:"required,gt=0"`
-    Timeout     time.Duration   `yaml:"timeout"     validate:"required,gt=0"`
-    ReverseMode bool            `yaml:"reverseMode"`
-    Protocol    string          `yaml:"protocol"    validate:"required,oneof=tcp udp"`
-    Bitrate     string          `yaml:"bitrate"     validate:"bitrate"` 
-    Bind        string          `yaml:"bind"`
-    Interval    time.Duration   `yaml:"interval"    validate:"gt=0"`
+    // Unique name of the target, added to its metrics as the name label
+    Name              string            `yaml:"name"`
+    Target            string            `yaml:"target"            validate:"required,hostname|ip"`
+    Port              int               `yaml:"port"              validate:"required,min=1,max=65535"`
+    Period            time.Duration     `yaml:"period"            validate:"required,gt=0"`
+    Timeout           time.Duration     `yaml:"timeout"           validate:"required,gt=0"`
+    ReverseMode       bool              `yaml:"reverseMode"`
+    Bidir             bool              `yaml:"bidir"             validate:"excluded_with=ReverseMode"`
+    Protocol          string            `yaml:"protocol"          validate:"required,oneof=tcp udp sctp"`
+    Bitrate           string            `yaml:"bitrate"           validate:"bitrate"`
+    Bind              string            `yaml:"bind"`
+    Parallel          int               `yaml:"parallel"          validate:"min=0,max=128"`
+    Interval          time.Duration     `yaml:"interval"          validate:"gt=0"`
+    Runner            string            `yaml:"runner"            validate:"omitempty,oneof=exec native replay command"`
+    // Command template run by the command runner
+    Command           []string          `yaml:"command"`
+    // Tool the target server runs, iperf3 or iperf2
+    Tool              string            `yaml:"tool"              validate:"omitempty,oneof=iperf3 iperf2"`
+    // Recorded iperf3 JSON output returned by the replay runner
+    Replay            string            `yaml:"replay"`
+    Stream            bool              `yaml:"stream"`
+    GetServerOutput   bool              `yaml:"getServerOutput"`
+    // CPU utilization in percent above which a result is flagged as CPU bound, 0 disables the flag
+    CPUBoundThreshold *float64          `yaml:"cpuBoundThreshold" validate:"omitempty,gte=0"`
+    Binary            string            `yaml:"iperf3Binary"`
+    Iperf2Binary      string            `yaml:"iperf2Binary"`
+    // Version of Binary, set by the exporter when the exec runner is used
+    BinaryVersion     string            `yaml:"-"`
+    // Custom labels added to every metric of the target
+    Labels            map[string]string `yaml:"labels"`
+}
+
+// reservedLabels are the label names of the metrics of a target, which its custom labels cannot use.
+var reservedLabels = []string{
+	"name", "target", "port", "protocol", "reverse", "reason", "binary", "version", "iperf3_version", "run_id",
+	"local_host", "local_port", "remote_host", "remote_port", "system_info", "cookie",
+	"num_streams", "blksize", "omit", "duration", "test_reverse", "tos",
+	"direction", "quantile", "side", "mode", "stream",
+}
+
+// labelNamePattern matches valid Prometheus label names.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ConstLabels returns the labels added to every metric of the target: its name, when
+// set, its custom labels and the version of its iperf3 binary, when known.
+func (t TargetConfig) ConstLabels() prometheus.Labels {
+	if t.Name == "" && len(t.Labels) == 0 && t.BinaryVersion == "" {
+		return nil
+	}
+
+	labels := make(prometheus.Labels, len(t.Labels)+2)
+	for name, value := range t.Labels {
+		labels[name] = value
+	}
+
+	if t.Name != "" {
+		labels["name"] = t.Name
+	}
+
+	if t.BinaryVersion != "" {
+		labels["iperf3_version"] = t.BinaryVersion
+	}
+
+	return labels
+}
+
+// ValidateLabels checks that the custom labels of a target are valid label names
+// that do not collide with the labels of its metrics.
+func ValidateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+
+		if slices.Contains(reservedLabels, name) {
+			return fmt.Errorf("label %q is reserved for the metrics of the target", name)
+		}
+	}
+
+	return nil
 }
 
 // Collector implements the prometheus.Collector interface for iperf3 metrics.
 type Collector struct {
-	target   string
-	port     int
-	period   time.Duration
-	timeout  time.Duration
-	mutex    sync.RWMutex
-	reverse  bool
-	protocol string
-	bitrate  string
-	bind     string
-	logger   *slog.Logger
-	runner   iperf.Runner
+	name            string
+	constLabels     prometheus.Labels
+	target          string
+	port            int
+	period          time.Duration
+	timeout         time.Duration
+	mutex           sync.RWMutex
+	reverse         bool
+	bidir           bool
+	protocol        string
+	bitrate         string
+	bind            string
+	parallel        int
+	tool            string
+	binary          string
+	version         string
+	getServerOutput bool
+	cpuThreshold    float64
+	logger          *slog.Logger
+	runner          iperf.Runner
+	// Called with every interval of a running test, see OnInterval
+	onInterval      func(iperf.Interval)
+	// Archive of the raw output of the runs, see ArchiveTo
+	archive         *archive.Archive
 
 	// Metrics
 	up              *prometheus.Desc
+	truncated       *prometheus.Desc
+	binaryInfo      *prometheus.Desc
+	lastRun         *prometheus.Desc
+	lastRunTime     *prometheus.Desc
+	resultAge       *prometheus.Desc
+	connectionInfo  *prometheus.Desc
+	testInfo        *prometheus.Desc
 	sentSeconds     *prometheus.Desc
 	sentBytes       *prometheus.Desc
 	receivedSeconds *prometheus.Desc
 	receivedBytes   *prometheus.Desc
+	// Bitrate metrics
+	sentBitsPerSecond     *prometheus.Desc
+	receivedBitsPerSecond *prometheus.Desc
+	requestedBitrate      *prometheus.Desc
 	// TCP-specific metrics
-	retransmits *prometheus.Desc
+	retransmits   *prometheus.Desc
+	tcpMinRTT     *prometheus.Desc
+	tcpMaxRTT     *prometheus.Desc
+	tcpMeanRTT    *prometheus.Desc
+	tcpMeanRTTVar *prometheus.Desc
+	tcpMaxSndCwnd *prometheus.Desc
+	tcpMaxSndWnd  *prometheus.Desc
 	// UDP-specific metrics
 	sentPackets     *prometheus.Desc
 	sentJitter      *prometheus.Desc
@@ -89,116 +204,441 @@ type Collector struct {
 	recvJitter      *prometheus.Desc
 	recvLostPackets *prometheus.Desc
 	recvLostPercent *prometheus.Desc
+	// Per-interval distribution metrics
+	intervalMinBitsPerSecond    *prometheus.Desc
+	intervalMaxBitsPerSecond    *prometheus.Desc
+	intervalStddevBitsPerSecond *prometheus.Desc
+	intervalBitsPerSecond       *prometheus.Desc
+	zeroByteIntervals           *prometheus.Desc
+	// Per-stream metrics
+	streamSentBytes             *prometheus.Desc
+	streamSentBitsPerSecond     *prometheus.Desc
+	streamReceivedBytes         *prometheus.Desc
+	streamReceivedBitsPerSecond *prometheus.Desc
+	streamRetransmits           *prometheus.Desc
+	fairnessIndex               *prometheus.Desc
+	// Bidirectional mode metrics
+	bidirSentSeconds           *prometheus.Desc
+	bidirSentBytes             *prometheus.Desc
+	bidirSentBitsPerSecond     *prometheus.Desc
+	bidirReceivedSeconds       *prometheus.Desc
+	bidirReceivedBytes         *prometheus.Desc
+	bidirReceivedBitsPerSecond *prometheus.Desc
+	bidirRetransmits           *prometheus.Desc
+	// Server side metrics, from the output of the server
+	serverReceivedBytes         *prometheus.Desc
+	serverReceivedBitsPerSecond *prometheus.Desc
+	serverSentBytes             *prometheus.Desc
+	serverSentBitsPerSecond     *prometheus.Desc
+	serverReceivedJitter        *prometheus.Desc
+	serverReceivedLostPackets   *prometheus.Desc
+	serverReceivedLostPercent   *prometheus.Desc
+	serverCPUUtilization        *prometheus.Desc
+	// CPU utilization metrics
+	cpuUtilization *prometheus.Desc
+	cpuBound       *prometheus.Desc
 }
 
 // NewCollector creates a new Collector for iperf3 metrics.
 func NewCollector(config TargetConfig, logger *slog.Logger) *Collector {
-	return NewCollectorWithRunner(config, logger, iperf.NewRunner(logger))
+	return NewCollectorWithRunner(config, logger, newRunner(config, logger))
+}
+
+// newRunner returns the iperf.Runner implementation selected by the target configuration.
+func newRunner(config TargetConfig, logger *slog.Logger) iperf.Runner {
+	if config.Tool == iperf.ToolIperf2 && config.Runner == iperf.RunnerExec {
+		return iperf.NewIperf2Runner(logger)
+	}
+
+	switch config.Runner {
+	case iperf.RunnerNative:
+		return iperf.NewNativeRunner(logger)
+	case iperf.RunnerReplay:
+		return iperf.NewReplayRunner(config.Replay, logger)
+	case iperf.RunnerCommand:
+		return iperf.NewCommandRunner(config.Command, logger)
+	default:
+		return iperf.NewRunner(logger)
+	}
 }
 
 // NewCollectorWithRunner creates a new Collector for iperf3 metrics with a custom runner.
 func NewCollectorWithRunner(config TargetConfig, logger *slog.Logger, runner iperf.Runner) *Collector {
 	// Common labels for all metrics
 	labels := []string{"target", "port", "protocol", "reverse"}
+	constLabels := config.ConstLabels()
+
+	// Targets without a threshold are not flagged
+	cpuThreshold := 0.0
+	if config.CPUBoundThreshold != nil {
+		cpuThreshold = *config.CPUBoundThreshold
+	}
 
 	return &Collector{
-		target:   config.Target,
-		port:     config.Port,
-		period:   config.Period,
-		timeout:  config.Timeout,
-		reverse:  config.ReverseMode,
-		protocol: config.Protocol,
-		bitrate:  config.Bitrate,
-		bind:     config.Bind,
-		logger:   logger,
-		runner:   runner,
+		name:            config.Name,
+		constLabels:     constLabels,
+		target:          config.Target,
+		port:            config.Port,
+		period:          config.Period,
+		timeout:         config.Timeout,
+		reverse:         config.ReverseMode,
+		bidir:           config.Bidir,
+		protocol:        config.Protocol,
+		bitrate:         config.Bitrate,
+		bind:            config.Bind,
+		parallel:        config.Parallel,
+		tool:            config.Tool,
+		binary:          config.Binary,
+		version:         config.BinaryVersion,
+		getServerOutput: config.GetServerOutput,
+		cpuThreshold:    cpuThreshold,
+		logger:          logger,
+		runner:          runner,
 
 		// Define metrics with labels
 		up: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "up"),
 			"Was the last iperf3 probe successful (1 for success, 0 for failure).",
-			labels, nil,
+			labels, constLabels,
+		),
+		truncated: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "result", "truncated"),
+			"Whether iperf3 was interrupted at the timeout and the last results only cover the part of the test before it (1 for truncated, 0 otherwise).",
+			labels, constLabels,
+		),
+		binaryInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "probe", "binary_info"),
+			"iperf3 binary and version used by the probe, always 1.",
+			append(append([]string{}, labels...), "binary", "version"), constLabels,
+		),
+		lastRun: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "last_run", "info"),
+			"ID of the last run in the result archive, always 1.",
+			append(append([]string{}, labels...), "run_id"), constLabels,
+		),
+		lastRunTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "last_run", "timestamp_seconds"),
+			"Time the last iperf3 test started, as reported by iperf3 when available.",
+			labels, constLabels,
+		),
+		resultAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "result", "age_seconds"),
+			"Time since the last test of the target finished, only exported for configured targets.",
+			labels, constLabels,
+		),
+		connectionInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connection_info"),
+			"Local and remote address of each stream of the last test, always 1.",
+			append(append([]string{}, labels...), "local_host", "local_port", "remote_host", "remote_port"), constLabels,
+		),
+		testInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "test_info"),
+			"iperf3 version, system and effective parameters of the last test, always 1.",
+			append(append([]string{}, labels...), "version", "system_info", "cookie", "num_streams", "blksize", "omit", "duration", "test_reverse", "tos"), constLabels,
 		),
 		sentSeconds: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "sent_seconds"),
 			"Total seconds spent sending packets.",
-			labels, nil,
+			labels, constLabels,
 		),
 		sentBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "sent_bytes"),
 			"Total sent bytes for the last test run.",
-			labels, nil,
+			labels, constLabels,
 		),
 		receivedSeconds: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "received_seconds"),
 			"Total seconds spent receiving packets.",
-			labels, nil,
+			labels, constLabels,
 		),
 		receivedBytes: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "received_bytes"),
 			"Total received bytes for the last test run.",
-			labels, nil,
+			labels, constLabels,
+		),
+		// Bitrate metrics
+		sentBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "sent_bits_per_second"),
+			"Average bitrate of the sender in the last test run, as reported by iperf3.",
+			labels, constLabels,
+		),
+		receivedBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "received_bits_per_second"),
+			"Average bitrate of the receiver in the last test run, as reported by iperf3.",
+			labels, constLabels,
+		),
+		requestedBitrate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "requested_bitrate_bits_per_second"),
+			"Bitrate the test is limited to, including the iperf3 default for UDP. Not exported for unlimited tests.",
+			labels, constLabels,
 		),
 		// TCP-specific metrics
 		retransmits: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "retransmits"),
 			"Total retransmits for the last test run.",
-			labels, nil,
+			labels, constLabels,
+		),
+		tcpMinRTT: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "min_rtt_seconds"),
+			"Lowest round-trip time reported by TCP_INFO during the last TCP test run.",
+			labels, constLabels,
+		),
+		tcpMaxRTT: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "max_rtt_seconds"),
+			"Highest round-trip time reported by TCP_INFO during the last TCP test run.",
+			labels, constLabels,
+		),
+		tcpMeanRTT: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "mean_rtt_seconds"),
+			"Mean round-trip time reported by TCP_INFO during the last TCP test run.",
+			labels, constLabels,
+		),
+		tcpMeanRTTVar: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "mean_rttvar_seconds"),
+			"Mean of the per-interval round-trip time variance during the last TCP test run.",
+			labels, constLabels,
+		),
+		tcpMaxSndCwnd: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "max_snd_cwnd_bytes"),
+			"Largest sender congestion window during the last TCP test run.",
+			labels, constLabels,
+		),
+		tcpMaxSndWnd: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tcp", "max_snd_wnd_bytes"),
+			"Largest sender window advertised by the receiver during the last TCP test run.",
+			labels, constLabels,
 		),
 		// UDP-specific metrics
 		sentPackets: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "sent_packets"),
 			"Total sent packets for the last UDP test run.",
-			labels, nil,
+			labels, constLabels,
 		),
 		sentJitter: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "sent_jitter_ms"),
 			"Jitter in milliseconds for sent packets in UDP.",
-			labels, nil,
+			labels, constLabels,
 		),
 		sentLostPackets: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "sent_lost_packets"),
 			"Total lost packets from the sender in the last UDP test run.",
-			labels, nil,
+			labels, constLabels,
 		),
 		sentLostPercent: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "sent_lost_percent"),
 			"Percentage of packets lost from the sender in the last UDP test run.",
-			labels, nil,
+			labels, constLabels,
 		),
 		recvPackets: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "received_packets"),
 			"Total received packets for the last UDP test run.",
-			labels, nil,
+			labels, constLabels,
 		),
 		recvJitter: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "received_jitter_ms"),
 			"Jitter in milliseconds for received packets in UDP.",
-			labels, nil,
+			labels, constLabels,
 		),
 		recvLostPackets: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "received_lost_packets"),
 			"Total lost packets at the receiver in the last UDP test run.",
-			labels, nil,
+			labels, constLabels,
 		),
 		recvLostPercent: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "received_lost_percent"),
 			"Percentage of packets lost at the receiver in the last UDP test run.",
-			labels, nil,
+			labels, constLabels,
+		),
+		// Per-interval distribution metrics
+		intervalMinBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "interval", "min_bits_per_second"),
+			"Lowest per-interval bitrate observed in the last test run.",
+			labels, constLabels,
+		),
+		intervalMaxBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "interval", "max_bits_per_second"),
+			"Highest per-interval bitrate observed in the last test run.",
+			labels, constLabels,
+		),
+		intervalStddevBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "interval", "stddev_bits_per_second"),
+			"Standard deviation of the per-interval bitrate in the last test run.",
+			labels, constLabels,
+		),
+		intervalBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "interval", "bits_per_second"),
+			"Quantiles of the per-interval bitrate in the last test run.",
+			append(labels, "quantile"), constLabels,
+		),
+		zeroByteIntervals: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "zero_byte_intervals"),
+			"Number of intervals in the last test run that transferred no data.",
+			labels, constLabels,
+		),
+		// Per-stream metrics
+		streamSentBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "stream", "sent_bytes"),
+			"Sent bytes of a single parallel stream for the last test run.",
+			append(labels, "stream"), constLabels,
+		),
+		streamSentBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "stream", "sent_bits_per_second"),
+			"Sender bitrate of a single parallel stream for the last test run.",
+			append(labels, "stream"), constLabels,
+		),
+		streamReceivedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "stream", "received_bytes"),
+			"Received bytes of a single parallel stream for the last TCP or SCTP test run.",
+			append(labels, "stream"), constLabels,
+		),
+		streamReceivedBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "stream", "received_bits_per_second"),
+			"Receiver bitrate of a single parallel stream for the last TCP or SCTP test run.",
+			append(labels, "stream"), constLabels,
+		),
+		streamRetransmits: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "stream", "retransmits"),
+			"Retransmits of a single parallel stream for the last TCP test run.",
+			append(labels, "stream"), constLabels,
+		),
+		fairnessIndex: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "fairness_index"),
+			"Jain's fairness index of the throughput across parallel streams (1 is perfectly fair).",
+			labels, constLabels,
+		),
+		// Bidirectional mode metrics
+		bidirSentSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bidir", "sent_seconds"),
+			"Total seconds spent sending packets per direction of the last bidirectional test run.",
+			append(labels, "direction"), constLabels,
+		),
+		bidirSentBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bidir", "sent_bytes"),
+			"Total sent bytes per direction of the last bidirectional test run.",
+			append(labels, "direction"), constLabels,
+		),
+		bidirSentBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bidir", "sent_bits_per_second"),
+			"Sender bitrate per direction of the last bidirectional test run.",
+			append(labels, "direction"), constLabels,
+		),
+		bidirReceivedSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bidir", "received_seconds"),
+			"Total seconds spent receiving packets per direction of the last bidirectional test run.",
+			append(labels, "direction"), constLabels,
+		),
+		bidirReceivedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bidir", "received_bytes"),
+			"Total received bytes per direction of the last bidirectional test run.",
+			append(labels, "direction"), constLabels,
+		),
+		bidirReceivedBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bidir", "received_bits_per_second"),
+			"Receiver bitrate per direction of the last bidirectional test run.",
+			append(labels, "direction"), constLabels,
+		),
+		bidirRetransmits: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bidir", "retransmits"),
+			"Total retransmits per direction of the last bidirectional TCP test run.",
+			append(labels, "direction"), constLabels,
+		),
+		// Server side metrics
+		serverReceivedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "received_bytes"),
+			"Total bytes received by the server in the last test run, as reported by the server.",
+			append(labels, "side"), constLabels,
+		),
+		serverReceivedBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "received_bits_per_second"),
+			"Receiver bitrate of the server in the last test run, as reported by the server.",
+			append(labels, "side"), constLabels,
+		),
+		serverSentBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "sent_bytes"),
+			"Total bytes sent by the server in the last test run, as reported by the server.",
+			append(labels, "side"), constLabels,
+		),
+		serverSentBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "sent_bits_per_second"),
+			"Sender bitrate of the server in the last test run, as reported by the server.",
+			append(labels, "side"), constLabels,
+		),
+		serverReceivedJitter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "received_jitter_ms"),
+			"Jitter in milliseconds of the packets received by the server in the last UDP test run.",
+			append(labels, "side"), constLabels,
+		),
+		serverReceivedLostPackets: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "received_lost_packets"),
+			"Total packets lost before reaching the server in the last UDP test run.",
+			append(labels, "side"), constLabels,
+		),
+		serverReceivedLostPercent: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "received_lost_percent"),
+			"Percentage of packets lost before reaching the server in the last UDP test run.",
+			append(labels, "side"), constLabels,
+		),
+		serverCPUUtilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_output", "cpu_utilization_percent"),
+			"CPU utilization of the server during the last test run by mode, as reported by the server.",
+			append(labels, "side", "mode"), constLabels,
+		),
+		// CPU utilization metrics
+		cpuUtilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "cpu_utilization_percent"),
+			"CPU utilization of the local host and the remote server during the last test run by mode.",
+			append(labels, "side", "mode"), constLabels,
+		),
+		cpuBound: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "result", "cpu_bound"),
+			"Whether the CPU utilization of either end exceeded the threshold in the last test run, making the result unreliable (1 for CPU bound, 0 otherwise).",
+			labels, constLabels,
 		),
 	}
 }
 
+// OnInterval sets a function called with every interval of a test while it is still running.
+func (c *Collector) OnInterval(fn func(iperf.Interval)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.onInterval = fn
+}
+
+// ArchiveTo sets an archive that the raw output of every run is stored in.
+func (c *Collector) ArchiveTo(a *archive.Archive) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.archive = a
+}
+
 // Describe implements the prometheus.Collector interface.
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.up
+	ch <- c.truncated
+	ch <- c.binaryInfo
+	ch <- c.lastRun
+	ch <- c.lastRunTime
+	ch <- c.resultAge
+	ch <- c.connectionInfo
+	ch <- c.testInfo
 	ch <- c.sentSeconds
 	ch <- c.sentBytes
 	ch <- c.receivedSeconds
 	ch <- c.receivedBytes
+	ch <- c.sentBitsPerSecond
+	ch <- c.receivedBitsPerSecond
+	ch <- c.requestedBitrate
 
 	// TCP-specific metrics
 	ch <- c.retransmits
+	ch <- c.tcpMinRTT
+	ch <- c.tcpMaxRTT
+	ch <- c.tcpMeanRTT
+	ch <- c.tcpMeanRTTVar
+	ch <- c.tcpMaxSndCwnd
+	ch <- c.tcpMaxSndWnd
 
 	// UDP-specific metrics
 	ch <- c.sentPackets
@@ -209,10 +649,63 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.recvJitter
 	ch <- c.recvLostPackets
 	ch <- c.recvLostPercent
+
+	// Per-interval distribution metrics
+	ch <- c.intervalMinBitsPerSecond
+	ch <- c.intervalMaxBitsPerSecond
+	ch <- c.intervalStddevBitsPerSecond
+	ch <- c.intervalBitsPerSecond
+	ch <- c.zeroByteIntervals
+
+	// Per-stream metrics
+	ch <- c.streamSentBytes
+	ch <- c.streamSentBitsPerSecond
+	ch <- c.streamReceivedBytes
+	ch <- c.streamReceivedBitsPerSecond
+	ch <- c.streamRetransmits
+	ch <- c.fairnessIndex
+
+	// Bidirectional mode metrics
+	ch <- c.bidirSentSeconds
+	ch <- c.bidirSentBytes
+	ch <- c.bidirSentBitsPerSecond
+	ch <- c.bidirReceivedSeconds
+	ch <- c.bidirReceivedBytes
+	ch <- c.bidirReceivedBitsPerSecond
+	ch <- c.bidirRetransmits
+	ch <- c.serverReceivedBytes
+	ch <- c.serverReceivedBitsPerSecond
+	ch <- c.serverSentBytes
+	ch <- c.serverSentBitsPerSecond
+	ch <- c.serverReceivedJitter
+	ch <- c.serverReceivedLostPackets
+	ch <- c.serverReceivedLostPercent
+	ch <- c.serverCPUUtilization
+
+	// CPU utilization metrics
+	ch <- c.cpuUtilization
+	ch <- c.cpuBound
+}
+
+// TestRun represents a finished test of a Collector, rendered into metrics by CollectRun.
+type TestRun struct {
+	Result iperf.Result
+	// ID of the run in the result archive, empty when it was not archived
+	ID string
+	// When the test started, as reported by iperf3 when available, and finished
+	Started  time.Time
+	Finished time.Time
 }
 
 // Collect implements the prometheus.Collector interface.
+// It runs a test and emits its metrics.
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectRun(ch, c.Run())
+}
+
+// Run runs a test of the target and returns it. The output of the test is archived
+// and a failed test is counted in the failure metrics.
+func (c *Collector) Run() TestRun {
 	c.mutex.Lock() // To protect metrics from concurrent collects.
 	defer c.mutex.Unlock()
 
@@ -221,40 +714,136 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 	defer cancel()
 
 	// Run iperf3 test
-	result := c.runner.Run(ctx, iperf.Config{
-		Target:      c.target,
-		Port:        c.port,
-		Period:      c.period,
-		Timeout:     c.timeout,
-		ReverseMode: c.reverse,
-		Protocol:    c.protocol,
-		Bitrate:     c.bitrate,
-		Bind:        c.bind,
-		Logger:		 c.logger,
+	run := TestRun{Started: time.Now()}
+	run.Result = c.runner.Run(ctx, iperf.Config{
+		Target:          c.target,
+		Port:            c.port,
+		Period:          c.period,
+		Timeout:         c.timeout,
+		ReverseMode:     c.reverse,
+		Bidir:           c.bidir,
+		Protocol:        c.protocol,
+		Bitrate:         c.bitrate,
+		Bind:            c.bind,
+		Parallel:        c.parallel,
+		Binary:          c.binary,
+		OnInterval:      c.onInterval,
+		GetServerOutput: c.getServerOutput,
+		Logger:          c.logger,
 	})
+	run.Finished = time.Now()
 
-	// Common label values for all metrics
-	labelValues := []string{
-		c.target, 
-		strconv.Itoa(c.port), 
-		c.protocol, 
+	// When the test ran, as reported by iperf3
+	if start := run.Result.Start; start != nil && !start.Timestamp.IsZero() {
+		run.Started = start.Timestamp
+	}
+
+	// Keep the raw output of the run, the run ID links the metrics to it
+	if c.archive != nil && run.Result.Output != nil {
+		if id, err := c.archiveRun(run.Result); err != nil {
+			c.logger.Warn("Failed to archive iperf3 run", "target", c.target, "port", c.port, "err", err)
+		} else {
+			run.ID = id
+		}
+	}
+
+	if !run.Result.Success {
+		IperfErrors.Inc()
+		Failures.Record(c.constLabels, c.labelValues(), run.Result.FailureReason)
+	}
+
+	return run
+}
+
+// reportsRetransmits reports whether the runner of the collector measures TCP retransmits.
+// Neither iperf2 nor the native runner do.
+func (c *Collector) reportsRetransmits() bool {
+	if _, native := c.runner.(*iperf.NativeRunner); native {
+		return false
+	}
+
+	return c.tool != iperf.ToolIperf2
+}
+
+// CollectResultAge emits how long ago the test run finished.
+func (c *Collector) CollectResultAge(ch chan<- prometheus.Metric, run TestRun, now time.Time) {
+	ch <- prometheus.MustNewConstMetric(c.resultAge, prometheus.GaugeValue, now.Sub(run.Finished).Seconds(), c.labelValues()...)
+}
+
+// labelValues returns the values of the labels common to all metrics of the target.
+func (c *Collector) labelValues() []string {
+	return []string{
+		c.target,
+		strconv.Itoa(c.port),
+		c.protocol,
 		strconv.FormatBool(c.reverse),
 	}
-	
+}
+
+// CollectRun emits the metrics of a finished test run.
+func (c *Collector) CollectRun(ch chan<- prometheus.Metric, run TestRun) {
+	result := run.Result
+
+	// Common label values for all metrics
+	labelValues := c.labelValues()
+
+	// The iperf3 binary in use, only known for the exec runner
+	if c.version != "" {
+		infoLabelValues := append(append([]string{}, labelValues...), iperf.BinaryOrDefault(c.binary), c.version)
+		ch <- prometheus.MustNewConstMetric(c.binaryInfo, prometheus.GaugeValue, 1, infoLabelValues...)
+	}
+
+	// The run ID links the metrics to the raw output in the archive
+	if run.ID != "" {
+		ch <- prometheus.MustNewConstMetric(c.lastRun, prometheus.GaugeValue, 1, append(append([]string{}, labelValues...), run.ID)...)
+	}
+
+	// When and how the test ran, as reported by iperf3
+	ch <- prometheus.MustNewConstMetric(c.lastRunTime, prometheus.GaugeValue, float64(run.Started.UnixNano())/1e9, labelValues...)
+
+	if result.Start != nil {
+		c.collectStartInfo(ch, labelValues, *result.Start)
+	}
+
+	// The configured limit, to compare the achieved bitrate against
+	if requested := iperf.RequestedBitrate(c.protocol, c.bitrate); requested > 0 {
+		ch <- prometheus.MustNewConstMetric(c.requestedBitrate, prometheus.GaugeValue, float64(requested), labelValues...)
+	}
+
 	// Set metrics based on result
 	if result.Success {
 		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1, labelValues...)
+
+		// Partial results of a test interrupted at the timeout
+		truncated := 0.0
+		if result.Truncated {
+			truncated = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.truncated, prometheus.GaugeValue, truncated, labelValues...)
+
 		ch <- prometheus.MustNewConstMetric(c.sentSeconds, prometheus.GaugeValue, result.SentSeconds, labelValues...)
 		ch <- prometheus.MustNewConstMetric(c.sentBytes, prometheus.GaugeValue, result.SentBytes, labelValues...)
 		ch <- prometheus.MustNewConstMetric(c.receivedSeconds, prometheus.GaugeValue, result.ReceivedSeconds, labelValues...)
 		ch <- prometheus.MustNewConstMetric(c.receivedBytes, prometheus.GaugeValue, result.ReceivedBytes, labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.sentBitsPerSecond, prometheus.GaugeValue, result.SentBitsPerSecond, labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.receivedBitsPerSecond, prometheus.GaugeValue, result.ReceivedBitsPerSecond, labelValues...)
 
-
-		// Retransmits is only relevant in TCP protocol
-		if result.Protocol == "tcp" {
+		// Retransmits is only relevant in TCP protocol, and not reported by every runner
+		if result.Protocol == "tcp" && c.reportsRetransmits() {
 			ch <- prometheus.MustNewConstMetric(c.retransmits, prometheus.GaugeValue, result.Retransmits, labelValues...)
 		}
 
+		// TCP_INFO derived metrics, only when the sender platform reported them
+		if result.Protocol == "tcp" && result.TCPInfo != nil {
+			ch <- prometheus.MustNewConstMetric(c.tcpMinRTT, prometheus.GaugeValue, result.TCPInfo.MinRTT.Seconds(), labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.tcpMaxRTT, prometheus.GaugeValue, result.TCPInfo.MaxRTT.Seconds(), labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.tcpMeanRTT, prometheus.GaugeValue, result.TCPInfo.MeanRTT.Seconds(), labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.tcpMeanRTTVar, prometheus.GaugeValue, result.TCPInfo.MeanRTTVar.Seconds(), labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.tcpMaxSndCwnd, prometheus.GaugeValue, result.TCPInfo.MaxSndCwnd, labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.tcpMaxSndWnd, prometheus.GaugeValue, result.TCPInfo.MaxSndWnd, labelValues...)
+		}
+
 		// Include UDP-specific metrics when in UDP protocol
 		if result.Protocol == "udp" {
 			ch <- prometheus.MustNewConstMetric(c.sentPackets, prometheus.GaugeValue, result.SentPackets, labelValues...)
@@ -266,16 +855,77 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(c.recvLostPackets, prometheus.GaugeValue, result.ReceivedLostPackets, labelValues...)
 			ch <- prometheus.MustNewConstMetric(c.recvLostPercent, prometheus.GaugeValue, result.ReceivedLostPercent, labelValues...)
 		}
+
+		// Per-interval distribution, only when iperf3 reported intervals
+		if stats, ok := iperf.SummarizeIntervals(result.Intervals); ok {
+			ch <- prometheus.MustNewConstMetric(c.intervalMinBitsPerSecond, prometheus.GaugeValue, stats.MinBitsPerSecond, labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.intervalMaxBitsPerSecond, prometheus.GaugeValue, stats.MaxBitsPerSecond, labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.intervalStddevBitsPerSecond, prometheus.GaugeValue, stats.StddevBitsPerSecond, labelValues...)
+			for _, q := range iperf.IntervalQuantiles {
+				ch <- prometheus.MustNewConstMetric(c.intervalBitsPerSecond, prometheus.GaugeValue, stats.BitsPerSecondQuantiles[q],
+					append(labelValues, strconv.FormatFloat(q, 'f', -1, 64))...)
+			}
+			ch <- prometheus.MustNewConstMetric(c.zeroByteIntervals, prometheus.GaugeValue, float64(stats.ZeroByteIntervals), labelValues...)
+		}
+
+		// Per-stream metrics, labelled by the stream's position in the iperf3 output
+		for i, stream := range result.Streams {
+			streamLabelValues := append(append([]string{}, labelValues...), strconv.Itoa(i))
+
+			ch <- prometheus.MustNewConstMetric(c.streamSentBytes, prometheus.GaugeValue, stream.SentBytes, streamLabelValues...)
+			ch <- prometheus.MustNewConstMetric(c.streamSentBitsPerSecond, prometheus.GaugeValue, stream.SentBitsPerSecond, streamLabelValues...)
+
+			// Receiver side is reported per stream in the stream oriented protocols
+			if result.Protocol == "tcp" || result.Protocol == "sctp" {
+				ch <- prometheus.MustNewConstMetric(c.streamReceivedBytes, prometheus.GaugeValue, stream.ReceivedBytes, streamLabelValues...)
+				ch <- prometheus.MustNewConstMetric(c.streamReceivedBitsPerSecond, prometheus.GaugeValue, stream.ReceivedBitsPerSecond, streamLabelValues...)
+			}
+
+			if result.Protocol == "tcp" && c.reportsRetransmits() {
+				ch <- prometheus.MustNewConstMetric(c.streamRetransmits, prometheus.GaugeValue, stream.Retransmits, streamLabelValues...)
+			}
+		}
+
+		if fairness, ok := result.FairnessIndex(); ok {
+			ch <- prometheus.MustNewConstMetric(c.fairnessIndex, prometheus.GaugeValue, fairness, labelValues...)
+		}
+
+		// Both directions of a bidirectional test, forward being client to server
+		if result.BidirReverse != nil {
+			c.collectDirection(ch, labelValues, "forward", iperf.DirectionResult{
+				SentSeconds:           result.SentSeconds,
+				SentBytes:             result.SentBytes,
+				SentBitsPerSecond:     result.SentBitsPerSecond,
+				ReceivedSeconds:       result.ReceivedSeconds,
+				ReceivedBytes:         result.ReceivedBytes,
+				ReceivedBitsPerSecond: result.ReceivedBitsPerSecond,
+				Retransmits:           result.Retransmits,
+			}, result.Protocol)
+			c.collectDirection(ch, labelValues, "reverse", *result.BidirReverse, result.Protocol)
+		}
+
+		// CPU utilization of both ends, when the runner reported it
+		if result.CPUUtilization != nil {
+			c.collectCPUUtilization(ch, labelValues, *result.CPUUtilization)
+		}
+
+		// The server's own view of the test, when its output was requested
+		if result.ServerOutput != nil && result.ServerOutput.Success {
+			c.collectServerOutput(ch, labelValues, *result.ServerOutput)
+		}
 	} else {
 		// Return common metrics with 0 values when iperf3 fails
 		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0, labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.truncated, prometheus.GaugeValue, 0, labelValues...)
 		ch <- prometheus.MustNewConstMetric(c.sentSeconds, prometheus.GaugeValue, 0, labelValues...)
 		ch <- prometheus.MustNewConstMetric(c.sentBytes, prometheus.GaugeValue, 0, labelValues...)
 		ch <- prometheus.MustNewConstMetric(c.receivedSeconds, prometheus.GaugeValue, 0, labelValues...)
 		ch <- prometheus.MustNewConstMetric(c.receivedBytes, prometheus.GaugeValue, 0, labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.sentBitsPerSecond, prometheus.GaugeValue, 0, labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.receivedBitsPerSecond, prometheus.GaugeValue, 0, labelValues...)
 
 		// Only include TCP-specific metrics for the active mode
-		if result.Protocol == "tcp" {
+		if result.Protocol == "tcp" && c.reportsRetransmits() {
 			// TCP-specific metrics on failure
 			ch <- prometheus.MustNewConstMetric(c.retransmits, prometheus.GaugeValue, 0, labelValues...)
 		}
@@ -290,7 +940,124 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(c.recvLostPackets, prometheus.GaugeValue, 0, labelValues...)
 			ch <- prometheus.MustNewConstMetric(c.recvLostPercent, prometheus.GaugeValue, 0, labelValues...)
 		}
+	}
+}
 
-		IperfErrors.Inc()
+// archiveRun stores the raw output of a run in the archive and returns its run ID.
+func (c *Collector) archiveRun(result iperf.Result) (string, error) {
+	run := archive.Run{
+		Name:     c.name,
+		Target:   c.target,
+		Port:     c.port,
+		Protocol: c.protocol,
+		Reverse:  c.reverse,
+		Success:  result.Success,
+		Command:  result.Output.Command,
+		ExitCode: result.Output.ExitCode,
+		Stderr:   string(result.Output.Stderr),
+	}
+
+	if result.Error != nil {
+		run.Error = result.Error.Error()
+	}
+
+	if json.Valid(result.Output.Stdout) {
+		run.Output = result.Output.Stdout
+	} else {
+		run.OutputText = string(result.Output.Stdout)
+	}
+
+	return c.archive.Store(run)
+}
+
+// collectStartInfo emits the connections and the effective parameters of the test.
+func (c *Collector) collectStartInfo(ch chan<- prometheus.Metric, labelValues []string, start iperf.StartInfo) {
+	for _, conn := range start.Connections {
+		connLabelValues := append(append([]string{}, labelValues...),
+			conn.LocalHost, strconv.Itoa(conn.LocalPort), conn.RemoteHost, strconv.Itoa(conn.RemotePort))
+
+		ch <- prometheus.MustNewConstMetric(c.connectionInfo, prometheus.GaugeValue, 1, connLabelValues...)
+	}
+
+	testLabelValues := append(append([]string{}, labelValues...),
+		start.Version,
+		start.SystemInfo,
+		start.Cookie,
+		strconv.Itoa(start.NumStreams),
+		strconv.Itoa(start.BlockSize),
+		strconv.Itoa(start.Omit),
+		strconv.Itoa(start.Duration),
+		strconv.FormatBool(start.Reverse),
+		strconv.Itoa(start.TOS),
+	)
+
+	ch <- prometheus.MustNewConstMetric(c.testInfo, prometheus.GaugeValue, 1, testLabelValues...)
+}
+
+// collectDirection emits the metrics of one direction of a bidirectional test.
+func (c *Collector) collectDirection(ch chan<- prometheus.Metric, labelValues []string, direction string, d iperf.DirectionResult, protocol string) {
+	directionLabelValues := append(append([]string{}, labelValues...), direction)
+
+	ch <- prometheus.MustNewConstMetric(c.bidirSentSeconds, prometheus.GaugeValue, d.SentSeconds, directionLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.bidirSentBytes, prometheus.GaugeValue, d.SentBytes, directionLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.bidirSentBitsPerSecond, prometheus.GaugeValue, d.SentBitsPerSecond, directionLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.bidirReceivedSeconds, prometheus.GaugeValue, d.ReceivedSeconds, directionLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.bidirReceivedBytes, prometheus.GaugeValue, d.ReceivedBytes, directionLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.bidirReceivedBitsPerSecond, prometheus.GaugeValue, d.ReceivedBitsPerSecond, directionLabelValues...)
+
+	if protocol == "tcp" {
+		ch <- prometheus.MustNewConstMetric(c.bidirRetransmits, prometheus.GaugeValue, d.Retransmits, directionLabelValues...)
+	}
+}
+
+// collectServerOutput emits the metrics of the test as reported by the server.
+func (c *Collector) collectServerOutput(ch chan<- prometheus.Metric, labelValues []string, server iperf.Result) {
+	serverLabelValues := append(append([]string{}, labelValues...), "server")
+
+	ch <- prometheus.MustNewConstMetric(c.serverReceivedBytes, prometheus.GaugeValue, server.ReceivedBytes, serverLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.serverReceivedBitsPerSecond, prometheus.GaugeValue, server.ReceivedBitsPerSecond, serverLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.serverSentBytes, prometheus.GaugeValue, server.SentBytes, serverLabelValues...)
+	ch <- prometheus.MustNewConstMetric(c.serverSentBitsPerSecond, prometheus.GaugeValue, server.SentBitsPerSecond, serverLabelValues...)
+
+	if server.Protocol == "udp" {
+		ch <- prometheus.MustNewConstMetric(c.serverReceivedJitter, prometheus.GaugeValue, server.ReceivedJitter, serverLabelValues...)
+		ch <- prometheus.MustNewConstMetric(c.serverReceivedLostPackets, prometheus.GaugeValue, server.ReceivedLostPackets, serverLabelValues...)
+		ch <- prometheus.MustNewConstMetric(c.serverReceivedLostPercent, prometheus.GaugeValue, server.ReceivedLostPercent, serverLabelValues...)
+	}
+
+	if cpu := server.CPUUtilization; cpu != nil {
+		ch <- prometheus.MustNewConstMetric(c.serverCPUUtilization, prometheus.GaugeValue, cpu.HostTotal, append(append([]string{}, serverLabelValues...), "total")...)
+		ch <- prometheus.MustNewConstMetric(c.serverCPUUtilization, prometheus.GaugeValue, cpu.HostUser, append(append([]string{}, serverLabelValues...), "user")...)
+		ch <- prometheus.MustNewConstMetric(c.serverCPUUtilization, prometheus.GaugeValue, cpu.HostSystem, append(append([]string{}, serverLabelValues...), "system")...)
+	}
+}
+
+// collectCPUUtilization emits the CPU utilization of both ends and whether either was CPU bound.
+func (c *Collector) collectCPUUtilization(ch chan<- prometheus.Metric, labelValues []string, cpu iperf.CPUUtilization) {
+	values := []struct {
+		side, mode string
+		value      float64
+	}{
+		{"host", "total", cpu.HostTotal},
+		{"host", "user", cpu.HostUser},
+		{"host", "system", cpu.HostSystem},
+		{"remote", "total", cpu.RemoteTotal},
+		{"remote", "user", cpu.RemoteUser},
+		{"remote", "system", cpu.RemoteSystem},
+	}
+
+	for _, v := range values {
+		ch <- prometheus.MustNewConstMetric(c.cpuUtilization, prometheus.GaugeValue, v.value,
+			append(append([]string{}, labelValues...), v.side, v.mode)...)
+	}
+
+	// A threshold of 0 disables the flag
+	if c.cpuThreshold > 0 {
+		bound := 0.0
+		if cpu.HostTotal > c.cpuThreshold || cpu.RemoteTotal > c.cpuThreshold {
+			bound = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.cpuBound, prometheus.GaugeValue, bound, labelValues...)
 	}
 }
@@ -0,0 +1,69 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strconv"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// currentLabels are the labels of the metrics of a running test, the same as the result metrics.
+var currentLabels = []string{"target", "port", "protocol", "reverse"}
+
+// CurrentMetrics returns the "current throughput" metrics of a running test of the target,
+// taken from the latest interval iperf3 reported.
+func CurrentMetrics(config TargetConfig, interval iperf.Interval) []*dto.MetricFamily {
+	registry := prometheus.NewRegistry()
+	constLabels := config.ConstLabels()
+
+	bitsPerSecond := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        prometheus.BuildFQName(namespace, "current", "bits_per_second"),
+		Help:        "Bitrate of the latest interval of the running test.",
+		ConstLabels: constLabels,
+	}, currentLabels)
+	elapsed := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        prometheus.BuildFQName(namespace, "current", "elapsed_seconds"),
+		Help:        "Time since the start of the running test.",
+		ConstLabels: constLabels,
+	}, currentLabels)
+	rtt := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        prometheus.BuildFQName(namespace, "current", "rtt_seconds"),
+		Help:        "Mean round-trip time of the latest interval of the running test (TCP mode only).",
+		ConstLabels: constLabels,
+	}, currentLabels)
+
+	registry.MustRegister(bitsPerSecond, elapsed, rtt)
+
+	labelValues := []string{
+		config.Target,
+		strconv.Itoa(config.Port),
+		config.Protocol,
+		strconv.FormatBool(config.ReverseMode),
+	}
+
+	bitsPerSecond.WithLabelValues(labelValues...).Set(interval.BitsPerSecond)
+	elapsed.WithLabelValues(labelValues...).Set(interval.End)
+
+	if interval.RTT > 0 {
+		rtt.WithLabelValues(labelValues...).Set(interval.RTT.Seconds())
+	}
+
+	// Gathering freshly created collectors cannot fail
+	families, _ := registry.Gather()
+
+	return families
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// targetFailures holds the failed probes of a single target.
+type targetFailures struct {
+	failures    *prometheus.Desc
+	lastFailure *prometheus.Desc
+	labelValues []string
+	counts      map[iperf.FailureReason]float64
+	last        iperf.FailureReason
+}
+
+// FailureCollector implements the prometheus.Collector interface for the failed probes of
+// the targets. Its metrics carry the name and the custom labels of every target, which differ
+// between targets, so it is an unchecked collector.
+type FailureCollector struct {
+	mu      sync.Mutex
+	targets map[string]*targetFailures
+}
+
+// NewFailureCollector creates a new FailureCollector.
+func NewFailureCollector() *FailureCollector {
+	return &FailureCollector{
+		targets: make(map[string]*targetFailures),
+	}
+}
+
+// Record counts a failed probe by reason and replaces the last failure reason of the target
+// with the given const labels and target, port, protocol and reverse label values.
+func (c *FailureCollector) Record(constLabels prometheus.Labels, labelValues []string, reason iperf.FailureReason) {
+	if reason == "" {
+		reason = iperf.FailureUnknown
+	}
+
+	key := failureKey(constLabels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.targets[key]
+	if !ok {
+		labels := []string{"target", "port", "protocol", "reverse", "reason"}
+
+		t = &targetFailures{
+			failures: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "probe", "failures_total"),
+				"Failed iperf3 probes by failure reason.",
+				labels, constLabels,
+			),
+			lastFailure: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "", "last_failure_info"),
+				"Reason of the last failed iperf3 probe of a target, always 1.",
+				labels, constLabels,
+			),
+			labelValues: append([]string{}, labelValues...),
+			counts:      make(map[iperf.FailureReason]float64),
+		}
+		c.targets[key] = t
+	}
+
+	t.counts[reason]++
+	t.last = reason
+}
+
+// failureKey identifies a target by its const labels and label values.
+func failureKey(constLabels prometheus.Labels, labelValues []string) string {
+	parts := make([]string, 0, len(constLabels)+len(labelValues))
+	for name, value := range constLabels {
+		parts = append(parts, name+"="+value)
+	}
+	sort.Strings(parts)
+
+	return strings.Join(append(parts, labelValues...), "\xff")
+}
+
+// Describe implements the prometheus.Collector interface. It sends no descriptors,
+// which makes the collector unchecked.
+func (c *FailureCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface.
+func (c *FailureCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.targets {
+		for reason, count := range t.counts {
+			ch <- prometheus.MustNewConstMetric(t.failures, prometheus.CounterValue, count,
+				append(append([]string{}, t.labelValues...), string(reason))...)
+		}
+
+		ch <- prometheus.MustNewConstMetric(t.lastFailure, prometheus.GaugeValue, 1,
+			append(append([]string{}, t.labelValues...), string(t.last))...)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// serverClientKey identifies the tests of a single client against an iperf3 server.
+type serverClientKey struct {
+	client   string
+	port     int
+	protocol string
+	reverse  bool
+}
+
+// serverClientEntry holds the last result and the test count of a client.
+type serverClientEntry struct {
+	result iperf.ServerResult
+	tests  float64
+}
+
+// ServerLogCollector implements the prometheus.Collector interface for the tests
+// received by iperf3 servers, as read from their JSON logs.
+type ServerLogCollector struct {
+	mu      sync.Mutex
+	clients map[serverClientKey]*serverClientEntry
+
+	// Metrics
+	tests                 *prometheus.Desc
+	lastTest              *prometheus.Desc
+	receivedSeconds       *prometheus.Desc
+	receivedBytes         *prometheus.Desc
+	receivedBitsPerSecond *prometheus.Desc
+	// UDP-specific metrics
+	receivedJitter      *prometheus.Desc
+	receivedLostPackets *prometheus.Desc
+	receivedLostPercent *prometheus.Desc
+}
+
+// NewServerLogCollector creates a new ServerLogCollector.
+func NewServerLogCollector() *ServerLogCollector {
+	labels := []string{"client", "port", "protocol", "reverse"}
+
+	return &ServerLogCollector{
+		clients: make(map[serverClientKey]*serverClientEntry),
+
+		tests: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "tests_total"),
+			"Number of successful tests received from the client.",
+			labels, nil,
+		),
+		lastTest: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "last_test_timestamp_seconds"),
+			"Start time of the last test received from the client.",
+			labels, nil,
+		),
+		receivedSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "received_seconds"),
+			"Total seconds spent receiving packets in the last test of the client.",
+			labels, nil,
+		),
+		receivedBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "received_bytes"),
+			"Total received bytes in the last test of the client.",
+			labels, nil,
+		),
+		receivedBitsPerSecond: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "received_bits_per_second"),
+			"Received throughput in the last test of the client.",
+			labels, nil,
+		),
+		receivedJitter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "received_jitter_ms"),
+			"Jitter in milliseconds for received packets in the last UDP test of the client.",
+			labels, nil,
+		),
+		receivedLostPackets: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "received_lost_packets"),
+			"Total lost packets at the receiver in the last UDP test of the client.",
+			labels, nil,
+		),
+		receivedLostPercent: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "server_client", "received_lost_percent"),
+			"Percentage of packets lost at the receiver in the last UDP test of the client.",
+			labels, nil,
+		),
+	}
+}
+
+// Record stores a test read from a server log. Failed tests are not recorded.
+func (c *ServerLogCollector) Record(result iperf.ServerResult) {
+	if !result.Success || result.Client == "" {
+		return
+	}
+
+	key := serverClientKey{
+		client:   result.Client,
+		port:     result.Port,
+		protocol: result.Protocol,
+		reverse:  result.Reverse,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.clients[key]
+	if !ok {
+		entry = &serverClientEntry{}
+		c.clients[key] = entry
+	}
+
+	entry.result = result
+	entry.tests++
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *ServerLogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tests
+	ch <- c.lastTest
+	ch <- c.receivedSeconds
+	ch <- c.receivedBytes
+	ch <- c.receivedBitsPerSecond
+	ch <- c.receivedJitter
+	ch <- c.receivedLostPackets
+	ch <- c.receivedLostPercent
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *ServerLogCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.clients {
+		labelValues := []string{key.client, strconv.Itoa(key.port), key.protocol, strconv.FormatBool(key.reverse)}
+		result := entry.result
+
+		ch <- prometheus.MustNewConstMetric(c.tests, prometheus.CounterValue, entry.tests, labelValues...)
+
+		if !result.Timestamp.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.lastTest, prometheus.GaugeValue, float64(result.Timestamp.Unix()), labelValues...)
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.receivedSeconds, prometheus.GaugeValue, result.ReceivedSeconds, labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.receivedBytes, prometheus.GaugeValue, result.ReceivedBytes, labelValues...)
+		ch <- prometheus.MustNewConstMetric(c.receivedBitsPerSecond, prometheus.GaugeValue, result.ReceivedBitsPerSecond, labelValues...)
+
+		if result.Protocol == "udp" {
+			ch <- prometheus.MustNewConstMetric(c.receivedJitter, prometheus.GaugeValue, result.ReceivedJitter, labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.receivedLostPackets, prometheus.GaugeValue, result.ReceivedLostPackets, labelValues...)
+			ch <- prometheus.MustNewConstMetric(c.receivedLostPercent, prometheus.GaugeValue, result.ReceivedLostPercent, labelValues...)
+		}
+	}
+}
@@ -16,14 +16,17 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	"gopkg.in/yaml.v3"
 	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
 	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/yuvaldekel/iperf3_exporter/internal/scheduler"
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/common/version"
 	"github.com/go-playground/validator/v10"
@@ -38,6 +41,22 @@ type configFile struct {
 	TLSKey  	  string				   `yaml:"tlsKey" json:"tls_key"`
     Interval      time.Duration   		   `yaml:"interval" json:"interval" validate:"gt=0"`
 	Timeout       time.Duration	  		   `yaml:"timeout" json:"timeout"`
+	Runner        string                   `yaml:"runner" json:"runner" validate:"oneof=exec native replay"`
+	// Recorded iperf3 JSON output to replay instead of running tests
+	Replay        string                   `yaml:"replay" json:"replay"`
+	Iperf3Binary  string                   `yaml:"iperf3Binary" json:"iperf3_binary" validate:"required"`
+	// iperf2 binary used by the targets with tool: iperf2
+	Iperf2Binary  string                   `yaml:"iperf2Binary" json:"iperf2_binary" validate:"required"`
+	// CPU utilization in percent above which results are flagged as CPU bound, 0 disables the flag
+	CPUBoundThreshold float64              `yaml:"cpuBoundThreshold" json:"cpu_bound_threshold" validate:"gte=0"`
+	// Number of target intervals after which a result is no longer exported, 0 keeps results forever
+	StaleIntervals float64                 `yaml:"staleIntervals" json:"stale_intervals" validate:"gte=0"`
+	// Number of tests run at the same time by targets and /probe, 0 for no limit
+	MaxConcurrentTests int                 `yaml:"maxConcurrentTests" json:"max_concurrent_tests" validate:"gte=0"`
+	// What to do when a target is due while its previous test still runs, skip or queue
+	OverlapPolicy string                   `yaml:"overlapPolicy" json:"overlap_policy" validate:"oneof=skip queue"`
+	// File keeping the time of the last test of every target across restarts
+	StateFile     string                   `yaml:"stateFile" json:"state_file"`
 
 	// Logging configuration for the exporter
 	Logging	struct {
@@ -46,6 +65,37 @@ type configFile struct {
 	} 									   `yaml:"logging"`
 
 	Targets 	  []collector.TargetConfig `yaml:"targets" json:"targets" validate:"dive" default:"[]"` 
+
+	// iperf3 servers managed by the exporter
+	Servers       []ServerConfig           `yaml:"servers" json:"servers" validate:"dive"`
+
+	// JSON logs of externally run iperf3 servers to ingest results from
+	ServerLogs    []ServerLogConfig        `yaml:"serverLogs" json:"server_logs" validate:"dive"`
+
+	// On-disk archive of the raw output of every run
+	Archive       ArchiveConfig            `yaml:"archive" json:"archive"`
+}
+
+// ServerConfig represents an iperf3 server process supervised by the exporter.
+type ServerConfig struct {
+	Port                int           `yaml:"port" json:"port" validate:"required,min=1,max=65535"`
+	Bind                string        `yaml:"bind" json:"bind" validate:"omitempty,ip"`
+	HealthCheckInterval time.Duration `yaml:"healthCheckInterval" json:"health_check_interval" validate:"gt=0"`
+	// Run the server with -J --logfile and ingest the results of the tests it serves
+	LogFile             string        `yaml:"logFile" json:"log_file" validate:"omitempty,filepath"`
+}
+
+// ServerLogConfig represents the JSON log file of an iperf3 server (iperf3 -s -J --logfile).
+type ServerLogConfig struct {
+	Path         string        `yaml:"path" json:"path" validate:"required,filepath"`
+	PollInterval time.Duration `yaml:"pollInterval" json:"poll_interval" validate:"gt=0"`
+}
+
+// ArchiveConfig represents the archive of raw iperf3 results, disabled when Path is empty.
+type ArchiveConfig struct {
+	Path     string        `yaml:"path" json:"path"`
+	MaxAge   time.Duration `yaml:"maxAge" json:"max_age" validate:"gte=0"`
+	MaxBytes int64         `yaml:"maxBytes" json:"max_bytes" validate:"gte=0"`
 }
 
 type argsConfig struct {
@@ -55,6 +105,14 @@ type argsConfig struct {
 	timeout        time.Duration	  	
 	loggingLevel   string
 	loggingFormat  string
+	runner         string
+	iperf3Binary   string
+	replay         string
+	cpuBoundThreshold float64
+	staleIntervals float64
+	maxConcurrentTests int
+	overlapPolicy  string
+	stateFile      string
 }
 
 // Config represents the runtime configuration for the iperf3_exporter.
@@ -65,7 +123,20 @@ type Config struct {
 	TLSCrt		  string
 	TLSKey  	  string
 	Timeout       time.Duration	  	
+	Runner        string
+	Replay        string
+	Iperf3Binary  string
+	CPUBoundThreshold float64
+	StaleIntervals float64
+	MaxConcurrentTests int
+	OverlapPolicy string
+	StateFile     string
 	Targets 	  []collector.TargetConfig 
+	Servers       []ServerConfig
+	ServerLogs    []ServerLogConfig
+	Archive       ArchiveConfig
+	// Versions of the iperf3 binaries in use, missing when they could not be detected
+	Iperf3Versions map[string]iperf.Version
 	Logger        *slog.Logger
 }
 
@@ -85,6 +156,16 @@ func newConfig() *configFile {
 		Timeout:       30 * time.Second,
 		Targets: 	  []collector.TargetConfig{},
 		Interval:	  3600 * time.Second,
+		Runner:        iperf.RunnerExec,
+		Iperf3Binary:  iperf.GetIperfCmd(),
+		Iperf2Binary:  iperf.GetIperf2Cmd(),
+		CPUBoundThreshold: 90,
+		StaleIntervals: collector.DefaultStaleIntervals,
+		OverlapPolicy: scheduler.OverlapSkip,
+		Archive: ArchiveConfig{
+			MaxAge:   7 * 24 * time.Hour,
+			MaxBytes: 100 * 1024 * 1024,
+		},
 		Logging: struct {
 			Level  string `yaml:"level" json:"level"`
 			Format string `yaml:"format" json:"format"`
@@ -136,9 +217,37 @@ func LoadConfig() *Config {
 		MetricsPath:   configFile.MetricsPath,
 		ProbePath:     configFile.ProbePath,
 		Timeout:       configFile.Timeout,
+		Runner:        configFile.Runner,
+		Replay:        configFile.Replay,
+		Iperf3Binary:  configFile.Iperf3Binary,
+		CPUBoundThreshold: configFile.CPUBoundThreshold,
+		StaleIntervals: configFile.StaleIntervals,
+		MaxConcurrentTests: configFile.MaxConcurrentTests,
+		OverlapPolicy: configFile.OverlapPolicy,
+		StateFile:     configFile.StateFile,
 		Targets: 	   configFile.Targets,
+		Servers:       configFile.Servers,
+		ServerLogs:    configFile.ServerLogs,
+		Archive:       configFile.Archive,
 		Logger:        logger,
 	}
+
+	// Detect the iperf3 versions so that options they do not support are rejected up front
+	cfg.Iperf3Versions = make(map[string]iperf.Version)
+	for _, binary := range cfg.Iperf3Binaries() {
+		if v, err := iperf.DetectVersion(binary); err != nil {
+			cfg.Logger.Warn("Unable to detect the iperf3 version", "binary", binary, "err", err)
+		} else {
+			cfg.Iperf3Versions[binary] = v
+			cfg.Logger.Info("Detected iperf3 version", "binary", binary, "version", v)
+		}
+	}
+
+	for i := range cfg.Targets {
+		if usesIperf3Binary(cfg.Targets[i]) {
+			cfg.Targets[i].BinaryVersion = cfg.Iperf3Versions[cfg.Targets[i].Binary].String()
+		}
+	}
 	
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
@@ -151,7 +260,12 @@ func LoadConfig() *Config {
 
 // ParseFlags parses the command line flags and returns a Config.
 func parseFlags() (string, *argsConfig){
-	argsConfig := new(argsConfig)
+	// Settings where 0 has a meaning start out negative, as not given
+	argsConfig := &argsConfig{
+		cpuBoundThreshold:  -1,
+		staleIntervals:     -1,
+		maxConcurrentTests: -1,
+	}
 
 	// Define command-line flags
 	configFilePath := kingpin.Flag("config", "Path to the configuration file").
@@ -173,6 +287,38 @@ func parseFlags() (string, *argsConfig){
 	    Envar("IPERF3_EXPORTER_TIMEOUT").
 		Default("").DurationVar(&argsConfig.timeout)
 
+	kingpin.Flag("runner", "Default way of running iperf3 tests. One of: [exec, native, replay]").
+		Envar("IPERF3_EXPORTER_RUNNER").
+		Default("").StringVar(&argsConfig.runner)
+
+	kingpin.Flag("replay", "Replay the recorded iperf3 JSON output in this file or directory instead of running tests.").
+		Envar("IPERF3_EXPORTER_REPLAY").
+		Default("").StringVar(&argsConfig.replay)
+
+	kingpin.Flag("iperf3-binary", "Path or name of the iperf3 binary used by the exec runner and managed servers.").
+		Envar("IPERF3_EXPORTER_BINARY").
+		Default("").StringVar(&argsConfig.iperf3Binary)
+
+	kingpin.Flag("cpu-bound-threshold", "CPU utilization in percent of either end above which a result is flagged as CPU bound, 0 disables the flag.").
+		Envar("IPERF3_EXPORTER_CPU_BOUND_THRESHOLD").
+		Float64Var(&argsConfig.cpuBoundThreshold)
+
+	kingpin.Flag("stale-intervals", "Number of target intervals after which a target result is no longer exported, 0 keeps results forever.").
+		Envar("IPERF3_EXPORTER_STALE_INTERVALS").
+		Float64Var(&argsConfig.staleIntervals)
+
+	kingpin.Flag("max-concurrent-tests", "Number of tests run at the same time by targets and /probe, 0 for no limit.").
+		Envar("IPERF3_EXPORTER_MAX_CONCURRENT_TESTS").
+		IntVar(&argsConfig.maxConcurrentTests)
+
+	kingpin.Flag("overlap-policy", "What to do when a target is due while its previous test still runs. One of: [skip, queue]").
+		Envar("IPERF3_EXPORTER_OVERLAP_POLICY").
+		Default("").StringVar(&argsConfig.overlapPolicy)
+
+	kingpin.Flag("state-file", "File keeping the time of the last test of every target, so that their schedule survives restarts.").
+		Envar("IPERF3_EXPORTER_STATE_FILE").
+		Default("").StringVar(&argsConfig.stateFile)
+
 	kingpin.Flag("log-level", "Only log messages with the given severity or above. One of: [debug, info, warn, error]").
         Envar("IPERF3_EXPORTER_LOG_LEVEL").
 		Default("").StringVar(&argsConfig.loggingLevel)
@@ -223,8 +369,52 @@ func loadConfigFromFile(path string, cfg *configFile, argsCfg *argsConfig) error
 	if argsCfg.loggingLevel != "" {
 		cfg.Logging.Level = argsCfg.loggingLevel
 	}
+	if argsCfg.runner != "" {
+		cfg.Runner = argsCfg.runner
+	}
+	if argsCfg.iperf3Binary != "" {
+		cfg.Iperf3Binary = argsCfg.iperf3Binary
+	}
+	if argsCfg.replay != "" {
+		cfg.Replay = argsCfg.replay
+	}
+	// Every test is replayed when recordings are given
+	if cfg.Replay != "" {
+		cfg.Runner = iperf.RunnerReplay
+	}
+	if argsCfg.cpuBoundThreshold >= 0 {
+		cfg.CPUBoundThreshold = argsCfg.cpuBoundThreshold
+	}
+	if argsCfg.staleIntervals >= 0 {
+		cfg.StaleIntervals = argsCfg.staleIntervals
+	}
+	if argsCfg.maxConcurrentTests >= 0 {
+		cfg.MaxConcurrentTests = argsCfg.maxConcurrentTests
+	}
+	if argsCfg.overlapPolicy != "" {
+		cfg.OverlapPolicy = argsCfg.overlapPolicy
+	}
+	if argsCfg.stateFile != "" {
+		cfg.StateFile = argsCfg.stateFile
+	}
 
 	for i := range cfg.Targets {
+        if cfg.Targets[i].Tool == "" {
+            cfg.Targets[i].Tool = iperf.ToolIperf3
+        }
+        // iperf2 listens on another port and can only be run as a binary
+        if cfg.Targets[i].Tool == iperf.ToolIperf2 {
+            if cfg.Targets[i].Port == 0 {
+                cfg.Targets[i].Port = 5001
+            }
+            if cfg.Targets[i].Runner == "" {
+                cfg.Targets[i].Runner = iperf.RunnerExec
+            }
+            if cfg.Targets[i].Iperf2Binary == "" {
+                cfg.Targets[i].Iperf2Binary = cfg.Iperf2Binary
+            }
+            cfg.Targets[i].Binary = cfg.Targets[i].Iperf2Binary
+        }
         if cfg.Targets[i].Port == 0 {
             cfg.Targets[i].Port = 5201
         }
@@ -240,6 +430,40 @@ func loadConfigFromFile(path string, cfg *configFile, argsCfg *argsConfig) error
         if cfg.Targets[i].Timeout == 0 {
             cfg.Targets[i].Timeout = cfg.Timeout
         }
+        if cfg.Targets[i].Runner == "" && len(cfg.Targets[i].Command) > 0 {
+            cfg.Targets[i].Runner = iperf.RunnerCommand
+        }
+        if cfg.Targets[i].Runner == "" {
+            cfg.Targets[i].Runner = cfg.Runner
+        }
+        if cfg.Targets[i].Replay == "" {
+            cfg.Targets[i].Replay = cfg.Replay
+        }
+        if cfg.Targets[i].Replay != "" {
+            cfg.Targets[i].Runner = iperf.RunnerReplay
+        }
+        if cfg.Targets[i].Binary == "" {
+            cfg.Targets[i].Binary = cfg.Iperf3Binary
+        }
+        if cfg.Targets[i].CPUBoundThreshold == nil {
+            threshold := cfg.CPUBoundThreshold
+            cfg.Targets[i].CPUBoundThreshold = &threshold
+        }
+	}
+
+	for i := range cfg.Servers {
+		if cfg.Servers[i].Port == 0 {
+			cfg.Servers[i].Port = 5201
+		}
+		if cfg.Servers[i].HealthCheckInterval == 0 {
+			cfg.Servers[i].HealthCheckInterval = 30 * time.Second
+		}
+	}
+
+	for i := range cfg.ServerLogs {
+		if cfg.ServerLogs[i].PollInterval == 0 {
+			cfg.ServerLogs[i].PollInterval = 5 * time.Second
+		}
 	}
 
 	var validate = validator.New()
@@ -276,5 +500,190 @@ func (c *Config) Validate() error {
 		return errors.New("logger cannot be nil")
 	}
 
+	if c.StaleIntervals < 0 {
+		return errors.New("stale intervals cannot be negative")
+	}
+
+	if c.MaxConcurrentTests < 0 {
+		return errors.New("max concurrent tests cannot be negative")
+	}
+
+	if c.OverlapPolicy != scheduler.OverlapSkip && c.OverlapPolicy != scheduler.OverlapQueue {
+		return errors.New("overlap policy must be 'skip' or 'queue'")
+	}
+
+	if c.Runner != iperf.RunnerExec && c.Runner != iperf.RunnerNative && c.Runner != iperf.RunnerReplay {
+		return errors.New("runner must be 'exec', 'native' or 'replay'")
+	}
+
+	if c.Runner == iperf.RunnerReplay {
+		if err := checkReplay(c.Replay); err != nil {
+			return err
+		}
+	}
+
+	servers := make(map[string]bool, len(c.Servers))
+	for _, srv := range c.Servers {
+		key := fmt.Sprintf("%s:%d", srv.Bind, srv.Port)
+		if servers[key] {
+			return fmt.Errorf("duplicate iperf3 server on port %d (bind %q)", srv.Port, srv.Bind)
+		}
+		servers[key] = true
+	}
+
+	names := make(map[string]bool, len(c.Targets))
+	for _, target := range c.Targets {
+		if target.Name == "" {
+			return fmt.Errorf("target %s:%d: missing target name, every target needs a unique 'name'", target.Target, target.Port)
+		}
+		if names[target.Name] {
+			return fmt.Errorf("target %s:%d: duplicate target name %q, give the targets a unique 'name'", target.Target, target.Port, target.Name)
+		}
+		names[target.Name] = true
+	}
+
+	for _, target := range c.Targets {
+		if err := collector.ValidateLabels(target.Labels); err != nil {
+			return fmt.Errorf("target %s:%d: %w", target.Target, target.Port, err)
+		}
+		if target.Runner == iperf.RunnerReplay {
+			if err := checkReplay(target.Replay); err != nil {
+				return fmt.Errorf("target %s:%d: %w", target.Target, target.Port, err)
+			}
+		}
+		if target.Runner == iperf.RunnerCommand && len(target.Command) == 0 {
+			return fmt.Errorf("target %s:%d: the command runner requires a 'command'", target.Target, target.Port)
+		}
+		if target.Tool == iperf.ToolIperf2 {
+			if err := checkIperf2Target(target); err != nil {
+				return fmt.Errorf("target %s:%d: %w", target.Target, target.Port, err)
+			}
+		}
+		if target.Runner == iperf.RunnerNative {
+			if err := CheckNativeTarget(target); err != nil {
+				return fmt.Errorf("target %s:%d: %w", target.Target, target.Port, err)
+			}
+		}
+		if usesIperf3Binary(target) && target.Bidir {
+			if err := c.Iperf3Versions[target.Binary].Require(iperf.CapabilityBidir); err != nil {
+				return fmt.Errorf("target %s:%d: %w", target.Target, target.Port, err)
+			}
+		}
+		if usesIperf3Binary(target) && target.Stream {
+			if err := c.Iperf3Versions[target.Binary].Require(iperf.CapabilityJSONStream); err != nil {
+				return fmt.Errorf("target %s:%d: %w", target.Target, target.Port, err)
+			}
+		}
+	}
+
+	logFiles := make(map[string]bool, len(c.Servers)+len(c.ServerLogs))
+	for _, srv := range c.Servers {
+		if srv.LogFile == "" {
+			continue
+		}
+		if logFiles[srv.LogFile] {
+			return fmt.Errorf("duplicate iperf3 server log file %q", srv.LogFile)
+		}
+		logFiles[srv.LogFile] = true
+	}
+	for _, serverLog := range c.ServerLogs {
+		if logFiles[serverLog.Path] {
+			return fmt.Errorf("duplicate iperf3 server log file %q", serverLog.Path)
+		}
+		logFiles[serverLog.Path] = true
+	}
+
+	return nil
+}
+
+// checkIperf2Target checks that a target tested with iperf2 only uses options iperf2 supports.
+func checkIperf2Target(target collector.TargetConfig) error {
+	switch {
+	case target.Runner != iperf.RunnerExec && target.Runner != iperf.RunnerReplay:
+		return fmt.Errorf("tool iperf2 requires the exec runner, got %q", target.Runner)
+	case target.Protocol != "tcp" && target.Protocol != "udp":
+		return fmt.Errorf("tool iperf2 does not support protocol %q", target.Protocol)
+	case target.Bidir:
+		return errors.New("tool iperf2 does not support 'bidir'")
+	case target.Stream:
+		return errors.New("tool iperf2 does not support 'stream'")
+	case target.GetServerOutput:
+		return errors.New("tool iperf2 does not support 'getServerOutput'")
+	}
+
+	return nil
+}
+
+// CheckNativeTarget checks that a target tested with the native runner only uses options it supports.
+func CheckNativeTarget(target collector.TargetConfig) error {
+	switch {
+	case target.Protocol != "tcp" && target.Protocol != "udp":
+		return fmt.Errorf("the native runner does not support protocol %q", target.Protocol)
+	case target.Bidir:
+		return errors.New("the native runner does not support 'bidir'")
+	}
+
+	return nil
+}
+
+// usesIperf3Binary reports whether target runs tests with the iperf3 binary.
+func usesIperf3Binary(target collector.TargetConfig) bool {
+	return target.Runner == iperf.RunnerExec && target.Tool != iperf.ToolIperf2
+}
+
+// checkReplay checks that the recordings of the replay runner exist.
+func checkReplay(path string) error {
+	if path == "" {
+		return errors.New("the replay runner requires recorded iperf3 output ('replay')")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("invalid replay recordings: %w", err)
+	}
+
 	return nil
 }
+
+// RequiresIperf3Binary reports whether an iperf3 binary is needed, i.e. whether
+// iperf3 servers are managed, or the /probe endpoint or any configured target
+// runs tests with the exec runner.
+func (c *Config) RequiresIperf3Binary() bool {
+	return len(c.Iperf3Binaries()) > 0
+}
+
+// Iperf3Binaries returns the distinct iperf3 binaries used by the managed servers,
+// the /probe endpoint and the configured targets.
+func (c *Config) Iperf3Binaries() []string {
+	var binaries []string
+
+	add := func(binary string) {
+		if !slices.Contains(binaries, binary) {
+			binaries = append(binaries, binary)
+		}
+	}
+
+	if c.Runner == iperf.RunnerExec || len(c.Servers) > 0 {
+		add(c.Iperf3Binary)
+	}
+
+	for _, target := range c.Targets {
+		if usesIperf3Binary(target) {
+			add(target.Binary)
+		}
+	}
+
+	return binaries
+}
+
+// Iperf2Binaries returns the distinct iperf2 binaries used by the configured targets.
+func (c *Config) Iperf2Binaries() []string {
+	var binaries []string
+
+	for _, target := range c.Targets {
+		if target.Tool == iperf.ToolIperf2 && !slices.Contains(binaries, target.Binary) {
+			binaries = append(binaries, target.Binary)
+		}
+	}
+
+	return binaries
+}
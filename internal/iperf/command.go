@@ -0,0 +1,128 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CommandRunner implements Runner by running an arbitrary command, such as a wrapper
+// around another throughput tool, that prints its results as iperf3 JSON on stdout.
+// Every argument of Command may contain the placeholders {target}, {port}, {period}
+// (in whole seconds), {bitrate}, {protocol}, {parallel} and {reverse}, which are
+// replaced with the values of the test. The command is run directly, not by a shell.
+//
+// The output follows the iperf3 -J schema, of which the following fields are used:
+// intervals[].sum, end.sum_sent and end.sum_received (TCP and SCTP), end.sum (UDP),
+// end.streams and error. A test fails when the command
+// exits with a non-zero status or the output has an error.
+type CommandRunner struct {
+	Command []string
+	Logger  *slog.Logger
+}
+
+// NewCommandRunner creates a new CommandRunner of the command template.
+func NewCommandRunner(command []string, logger *slog.Logger) *CommandRunner {
+	return &CommandRunner{
+		Command: command,
+		Logger:  logger,
+	}
+}
+
+// Run executes the command with the placeholders replaced by cfg and returns the parsed results.
+func (r *CommandRunner) Run(ctx context.Context, cfg Config) Result {
+	result := Result{
+		Success:  false,
+		Protocol: cfg.Protocol,
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = r.Logger
+	}
+
+	if len(r.Command) == 0 {
+		result.Error = errors.New("no command configured for the command runner")
+		result.FailureReason = FailureUnknown
+
+		return result
+	}
+
+	args := ExpandCommand(r.Command, cfg)
+	name := filepath.Base(args[0])
+
+	// #nosec G204 - the command comes from the exporter configuration
+	var cmd *exec.Cmd
+	if ctx != nil {
+		cmd = execCommandContext(ctx, args[0], args[1:]...)
+	} else {
+		cmd = execCommand(args[0], args[1:]...)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	cfg.Logger.Debug("Running test command", "target", cfg.Target, "port", cfg.Port, "command", args)
+
+	out, err := cmd.Output()
+
+	result.Output = &RawOutput{
+		Command:  cmd.Args,
+		Stdout:   out,
+		Stderr:   stderr.Bytes(),
+		ExitCode: -1,
+	}
+
+	if cmd.ProcessState != nil {
+		result.Output.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	if !parseOutput(ctx, cfg, &result, name, out, stderr.String(), err) {
+		return result
+	}
+
+	cfg.Logger.Debug("Test command completed successfully",
+		"target", cfg.Target,
+		"sent_bps", result.SentBitsPerSecond,
+		"received_bps", result.ReceivedBitsPerSecond,
+	)
+
+	return result
+}
+
+// ExpandCommand returns command with the placeholders replaced by the values of cfg.
+func ExpandCommand(command []string, cfg Config) []string {
+	replacer := strings.NewReplacer(
+		"{target}", cfg.Target,
+		"{port}", strconv.Itoa(cfg.Port),
+		"{period}", strconv.FormatFloat(cfg.Period.Seconds(), 'f', 0, 64),
+		"{bitrate}", cfg.Bitrate,
+		"{protocol}", cfg.Protocol,
+		"{parallel}", strconv.Itoa(max(cfg.Parallel, 1)),
+		"{reverse}", strconv.FormatBool(cfg.ReverseMode),
+	)
+
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	return args
+}
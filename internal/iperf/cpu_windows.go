@@ -0,0 +1,43 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package iperf
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns the user and system CPU time consumed by the exporter process.
+func processCPUTime() (user, system time.Duration, ok bool) {
+	var creation, exit, kernel, userTime syscall.Filetime
+
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if err := syscall.GetProcessTimes(process, &creation, &exit, &kernel, &userTime); err != nil {
+		return 0, 0, false
+	}
+
+	// Filetime counts 100 nanosecond intervals
+	return filetimeDuration(userTime), filetimeDuration(kernel), true
+}
+
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
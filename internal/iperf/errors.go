@@ -0,0 +1,82 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// FailureReason classifies why an iperf3 test failed.
+type FailureReason string
+
+// The fixed set of failure reasons, used as the value of the reason label.
+const (
+	FailureConnectionRefused FailureReason = "connection_refused"
+	FailureServerBusy        FailureReason = "server_busy"
+	FailureDNS               FailureReason = "dns_failure"
+	FailureTimeout           FailureReason = "timeout"
+	FailureAuth              FailureReason = "auth_failure"
+	FailureParse             FailureReason = "parse_error"
+	FailureUnknown           FailureReason = "unknown"
+)
+
+// FailureReasons lists every failure reason.
+var FailureReasons = []FailureReason{
+	FailureConnectionRefused,
+	FailureServerBusy,
+	FailureDNS,
+	FailureTimeout,
+	FailureAuth,
+	FailureParse,
+	FailureUnknown,
+}
+
+// failurePatterns maps lower-cased fragments of iperf3 error messages to a reason.
+// The first matching entry wins, so more specific fragments come first.
+var failurePatterns = []struct {
+	fragment string
+	reason   FailureReason
+}{
+	{"server is busy", FailureServerBusy},
+	{"authorization failed", FailureAuth},
+	{"authentication", FailureAuth},
+	{"unable to resolve", FailureDNS},
+	{"name or service not known", FailureDNS},
+	{"nodename nor servname", FailureDNS},
+	{"temporary failure in name resolution", FailureDNS},
+	{"no address associated with hostname", FailureDNS},
+	{"connection refused", FailureConnectionRefused},
+	{"timed out", FailureTimeout},
+	{"timeout", FailureTimeout},
+}
+
+// ClassifyFailure maps an iperf3 error message to a failure reason.
+// ctxErr is the error of the context the test ran under, if any; a test that
+// was killed because its context expired is always classified as a timeout.
+func ClassifyFailure(message string, ctxErr error) FailureReason {
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		return FailureTimeout
+	}
+
+	message = strings.ToLower(message)
+	for _, p := range failurePatterns {
+		if strings.Contains(message, p.fragment) {
+			return p.reason
+		}
+	}
+
+	return FailureUnknown
+}
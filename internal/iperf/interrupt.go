@@ -0,0 +1,84 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultGracePeriod is how long before the deadline of a test iperf3 is interrupted,
+// to give it time to print the results measured so far.
+const DefaultGracePeriod = 2 * time.Second
+
+// interruptContext returns the context a test should run with: it ends the grace
+// period before the deadline of ctx, and the grace period left for the test to exit
+// after it ends. The grace period is at most half of the time left until the deadline.
+func interruptContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc, time.Duration) {
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		runCtx, cancel := context.WithCancel(ctx)
+
+		return runCtx, cancel, grace
+	}
+
+	grace = min(grace, time.Until(deadline)/2)
+	runCtx, cancel := context.WithDeadline(ctx, deadline.Add(-grace))
+
+	return runCtx, cancel, grace
+}
+
+// gracefulStop makes cmd, created with the context of interruptContext, interrupt the
+// process instead of killing it when the context ends. The process group is killed
+// only if the process is still running once the grace period is over. The returned
+// function has to be called after the process exited, it kills whatever the process
+// left running in its group after an interrupt.
+func gracefulStop(cmd *exec.Cmd, grace time.Duration) func() {
+	var (
+		mu   sync.Mutex
+		pid  int
+		kill *time.Timer
+	)
+
+	setProcessGroup(cmd)
+
+	cmd.Cancel = func() error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		pid = cmd.Process.Pid
+		kill = time.AfterFunc(grace, func() { killProcessGroup(pid) })
+
+		return interruptProcess(cmd.Process)
+	}
+
+	// Stop waiting for the output once the process group should be gone, even if
+	// something escaped the group and still holds the pipes
+	cmd.WaitDelay = 2 * grace
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if kill != nil && kill.Stop() {
+			killProcessGroup(pid)
+		}
+	}
+}
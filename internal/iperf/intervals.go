@@ -0,0 +1,101 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"math"
+	"sort"
+)
+
+// IntervalQuantiles are the quantiles reported for the per-interval bitrate.
+var IntervalQuantiles = []float64{0.05, 0.5, 0.95}
+
+// IntervalStats summarizes the distribution of the per-interval bitrate of a test.
+type IntervalStats struct {
+	Count                  int
+	ZeroByteIntervals      int
+	MinBitsPerSecond       float64
+	MaxBitsPerSecond       float64
+	StddevBitsPerSecond    float64
+	BitsPerSecondQuantiles map[float64]float64
+}
+
+// SummarizeIntervals computes the distribution of the per-interval bitrate.
+// Intervals flagged as omitted (TCP slow start skipped with -O) are ignored.
+// The second return value is false when there are no intervals to summarize.
+func SummarizeIntervals(intervals []Interval) (IntervalStats, bool) {
+	samples := make([]float64, 0, len(intervals))
+	stats := IntervalStats{}
+
+	for _, interval := range intervals {
+		if interval.Omitted {
+			continue
+		}
+
+		if interval.Bytes == 0 {
+			stats.ZeroByteIntervals++
+		}
+
+		samples = append(samples, interval.BitsPerSecond)
+	}
+
+	if len(samples) == 0 {
+		return stats, false
+	}
+
+	sort.Float64s(samples)
+
+	stats.Count = len(samples)
+	stats.MinBitsPerSecond = samples[0]
+	stats.MaxBitsPerSecond = samples[len(samples)-1]
+
+	var sum float64
+	for _, v := range samples {
+		sum += v
+	}
+
+	mean := sum / float64(len(samples))
+
+	var variance float64
+	for _, v := range samples {
+		variance += (v - mean) * (v - mean)
+	}
+
+	stats.StddevBitsPerSecond = math.Sqrt(variance / float64(len(samples)))
+
+	stats.BitsPerSecondQuantiles = make(map[float64]float64, len(IntervalQuantiles))
+	for _, q := range IntervalQuantiles {
+		stats.BitsPerSecondQuantiles[q] = quantile(samples, q)
+	}
+
+	return stats, true
+}
+
+// quantile returns the q-quantile of sorted samples using linear interpolation
+// between the closest ranks.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
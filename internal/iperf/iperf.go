@@ -22,7 +22,9 @@ import (
 	"log/slog"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -70,6 +72,7 @@ type Result struct {
 	Protocol              string
 	// TCP-specific fields
 	Retransmits float64
+	TCPInfo     *TCPInfo
 	// UDP-specific fields
 	SentPackets         float64
 	SentJitter          float64
@@ -79,36 +82,271 @@ type Result struct {
 	ReceivedJitter      float64
 	ReceivedLostPackets float64
 	ReceivedLostPercent float64
-	Error               error
+	// Server to client direction, only set in bidirectional mode
+	BidirReverse *DirectionResult
+	// Per-stream results, one entry per parallel stream
+	Streams []StreamResult
+	// Per-interval samples, in the order reported by iperf3
+	Intervals []Interval
+	// CPU utilization of both ends, when reported
+	CPUUtilization *CPUUtilization
+	// What iperf3 reported when the test started, when reported
+	Start *StartInfo
+	// Raw output of the iperf3 process, only set by the exec runner
+	Output *RawOutput
+	// Whether iperf3 was interrupted at the timeout and the results only cover
+	// the part of the test that ran until then
+	Truncated bool
+	// The server's own view of the test, only set when its output was requested
+	ServerOutput *Result
+	Error        error
+	// Why the test failed, only set when Success is false
+	FailureReason FailureReason
+}
+
+// DirectionResult represents the result of one direction of a bidirectional test.
+type DirectionResult struct {
+	SentSeconds           float64
+	SentBytes             float64
+	SentBitsPerSecond     float64
+	ReceivedSeconds       float64
+	ReceivedBytes         float64
+	ReceivedBitsPerSecond float64
+	// TCP-specific fields
+	Retransmits float64
+}
+
+// StartInfo represents the connections and the effective parameters iperf3 reported for a test.
+type StartInfo struct {
+	Version    string
+	SystemInfo string
+	Cookie     string
+	Timestamp  time.Time
+	// One entry per stream of the test
+	Connections []Connection
+	// Effective test parameters, the block size is in bytes and the durations in seconds
+	NumStreams int
+	BlockSize  int
+	Omit       int
+	Duration   int
+	Reverse    bool
+	TOS        int
+}
+
+// Connection represents the addresses of one stream of a test.
+type Connection struct {
+	LocalHost  string
+	LocalPort  int
+	RemoteHost string
+	RemotePort int
+}
+
+// RawOutput represents what an iperf3 process was run with and printed.
+type RawOutput struct {
+	Command  []string
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// CPUUtilization represents the CPU utilization in percent of the local host and the remote end of a test.
+type CPUUtilization struct {
+	HostTotal    float64
+	HostUser     float64
+	HostSystem   float64
+	RemoteTotal  float64
+	RemoteUser   float64
+	RemoteSystem float64
+}
+
+// swap returns the utilization from the point of view of the remote end.
+func (c *CPUUtilization) swap() *CPUUtilization {
+	if c == nil {
+		return nil
+	}
+
+	return &CPUUtilization{
+		HostTotal:    c.RemoteTotal,
+		HostUser:     c.RemoteUser,
+		HostSystem:   c.RemoteSystem,
+		RemoteTotal:  c.HostTotal,
+		RemoteUser:   c.HostUser,
+		RemoteSystem: c.HostSystem,
+	}
+}
+
+// StreamResult represents the result of a single parallel stream of an iperf3 test.
+type StreamResult struct {
+	Socket                int
+	SentBytes             float64
+	SentBitsPerSecond     float64
+	ReceivedBytes         float64
+	ReceivedBitsPerSecond float64
+	// TCP-specific fields
+	Retransmits float64
+}
+
+// Interval represents a single reporting interval of an iperf3 test.
+type Interval struct {
+	Start         float64
+	End           float64
+	Seconds       float64
+	Bytes         float64
+	BitsPerSecond float64
+	Omitted       bool
+	// TCP-specific fields, averaged over the streams of the interval
+	RTT    time.Duration
+	RTTVar time.Duration
+}
+
+// TCPInfo contains the TCP_INFO derived statistics of the sending side of a TCP test.
+// It is only available when the sender platform exposes TCP_INFO (e.g. Linux).
+type TCPInfo struct {
+	MaxSndCwnd float64
+	MaxSndWnd  float64
+	MinRTT     time.Duration
+	MaxRTT     time.Duration
+	MeanRTT    time.Duration
+	// Mean of the per-interval RTT variance
+	MeanRTTVar time.Duration
 }
 
 // rawResult collects the partial result from the iperf3 run.
 type rawResult struct {
-	Start struct {
+	// Set by iperf3 when the test failed, sometimes with exit code 0
+	Error     string             `json:"error"`
+	Intervals []rawIntervalEntry `json:"intervals"`
+	Start     struct {
+		// Only written by the server side of a test
+		AcceptedConnection struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		} `json:"accepted_connection"`
+		Connected []struct {
+			LocalHost  string `json:"local_host"`
+			LocalPort  int    `json:"local_port"`
+			RemoteHost string `json:"remote_host"`
+			RemotePort int    `json:"remote_port"`
+		} `json:"connected"`
+		Version    string `json:"version"`
+		SystemInfo string `json:"system_info"`
+		Cookie     string `json:"cookie"`
+		Timestamp  struct {
+			Timesecs int64 `json:"timesecs"`
+		} `json:"timestamp"`
 		TestStart struct {
-			Protocol string `json:"protocol"`
+			Protocol   string `json:"protocol"`
+			NumStreams int    `json:"num_streams"`
+			BlockSize  int    `json:"blksize"`
+			Omit       int    `json:"omit"`
+			Duration   int    `json:"duration"`
+			Reverse    int    `json:"reverse"`
+			Bidir      int    `json:"bidir"`
+			TOS        int    `json:"tos"`
 		} `json:"test_start"`
 	} `json:"start"`
 	End struct {
 		// TCP mode uses these fields
-		SumSent struct {
-			Seconds       float64 `json:"seconds"`
-			Bytes         float64 `json:"bytes"`
-			BitsPerSecond float64 `json:"bits_per_second"`
-			Retransmits   float64 `json:"retransmits"`
-		} `json:"sum_sent"`
-		SumReceived struct {
-			Seconds       float64 `json:"seconds"`
-			Bytes         float64 `json:"bytes"`
-			BitsPerSecond float64 `json:"bits_per_second"`
-		} `json:"sum_received"`
-
-		// UDP mode specific structure
+		SumSent     rawSum `json:"sum_sent"`
+		SumReceived rawSum `json:"sum_received"`
+
+		// Server to client direction in bidirectional mode
+		SumSentBidirReverse     rawSum `json:"sum_sent_bidir_reverse"`
+		SumReceivedBidirReverse rawSum `json:"sum_received_bidir_reverse"`
+
+		// Per-stream results, sender/receiver in TCP mode and udp in UDP mode
 		Streams []struct {
-			UDP UDPInfo `json:"udp"`
+			Sender   rawStreamSide `json:"sender"`
+			Receiver rawStreamSide `json:"receiver"`
+			UDP      UDPInfo       `json:"udp"`
 		} `json:"streams"`
 		Sum UDPInfo `json:"sum"`
+
+		CPUUtilizationPercent *struct {
+			HostTotal    float64 `json:"host_total"`
+			HostUser     float64 `json:"host_user"`
+			HostSystem   float64 `json:"host_system"`
+			RemoteTotal  float64 `json:"remote_total"`
+			RemoteUser   float64 `json:"remote_user"`
+			RemoteSystem float64 `json:"remote_system"`
+		} `json:"cpu_utilization_percent"`
 	} `json:"end"`
+
+	// Output of the server, only set with --get-server-output
+	ServerOutputJSON *rawResult `json:"server_output_json"`
+}
+
+// rawSum contains the summed metrics of one side of a test.
+type rawSum struct {
+	Seconds       float64 `json:"seconds"`
+	Bytes         float64 `json:"bytes"`
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Retransmits   float64 `json:"retransmits"`
+}
+
+// rawIntervalEntry contains a single reporting interval with its per-stream details.
+type rawIntervalEntry struct {
+	Streams []struct {
+		RTT    float64 `json:"rtt"`
+		RTTVar float64 `json:"rttvar"`
+	} `json:"streams"`
+	Sum rawInterval `json:"sum"`
+}
+
+// interval converts the entry to an Interval, averaging the TCP RTT over the streams.
+func (e rawIntervalEntry) interval() Interval {
+	var rtt, rttVar float64
+	for _, stream := range e.Streams {
+		rtt += stream.RTT
+		rttVar += stream.RTTVar
+	}
+
+	if n := len(e.Streams); n > 0 {
+		rtt /= float64(n)
+		rttVar /= float64(n)
+	}
+
+	return Interval{
+		Start:         e.Sum.Start,
+		End:           e.Sum.End,
+		Seconds:       e.Sum.Seconds,
+		Bytes:         e.Sum.Bytes,
+		BitsPerSecond: e.Sum.BitsPerSecond,
+		Omitted:       e.Sum.Omitted,
+		RTT:           microseconds(rtt),
+		RTTVar:        microseconds(rttVar),
+	}
+}
+
+// rawInterval contains the summed metrics of a single reporting interval.
+type rawInterval struct {
+	Start         float64 `json:"start"`
+	End           float64 `json:"end"`
+	Seconds       float64 `json:"seconds"`
+	Bytes         float64 `json:"bytes"`
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Omitted       bool    `json:"omitted"`
+	// UDP-specific fields, only reported by the receiver
+	JitterMs    float64 `json:"jitter_ms"`
+	LostPackets float64 `json:"lost_packets"`
+	Packets     float64 `json:"packets"`
+}
+
+// rawStreamSide contains the metrics of one side of a single TCP stream.
+type rawStreamSide struct {
+	Socket        int     `json:"socket"`
+	Seconds       float64 `json:"seconds"`
+	Bytes         float64 `json:"bytes"`
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Retransmits   float64 `json:"retransmits"`
+	// TCP_INFO derived fields, RTTs are in microseconds
+	MaxSndCwnd float64 `json:"max_snd_cwnd"`
+	MaxSndWnd  float64 `json:"max_snd_wnd"`
+	MaxRTT     float64 `json:"max_rtt"`
+	MinRTT     float64 `json:"min_rtt"`
+	MeanRTT    float64 `json:"mean_rtt"`
+	// Whether the local side sent on the stream, false for the server to client streams in bidirectional mode
+	Sender bool `json:"sender"`
 }
 
 // UDPInfo contains the UDP specific metrics
@@ -133,12 +371,46 @@ type Config struct {
 	Period      time.Duration
 	Timeout     time.Duration
 	ReverseMode bool
+	Bidir       bool
 	Protocol    string
 	Bitrate     string
 	Bind        string
+	Parallel    int
+	// Request the server's own results with --get-server-output
+	GetServerOutput bool
+	// Path or name of the iperf3 binary, GetIperfCmd() when empty
+	Binary string
+	// Called with every interval while the test runs. The exec runner then runs
+	// iperf3 with --json-stream to receive the intervals as they are reported.
+	OnInterval func(Interval)
+	// How long before the timeout the exec runner interrupts iperf3 to still
+	// receive its results, DefaultGracePeriod when zero
+	GracePeriod time.Duration
 	Logger      *slog.Logger
 }
 
+// binary returns the iperf3 binary to run for cfg.
+func (cfg Config) binary() string {
+	return BinaryOrDefault(cfg.Binary)
+}
+
+// BinaryOrDefault returns binary, or the platform default iperf3 command when it is empty.
+func BinaryOrDefault(binary string) string {
+	if binary == "" {
+		return GetIperfCmd()
+	}
+
+	return binary
+}
+
+// Protocols lists the transport protocols supported by the exporter.
+var Protocols = []string{"tcp", "udp", "sctp"}
+
+// ValidateProtocol validates the transport protocol.
+func ValidateProtocol(protocol string) bool {
+	return slices.Contains(Protocols, protocol)
+}
+
 var bitratePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([KMG])?(\/[0-9]+)?$`)
 
 // ValidateBitrate validates the bitrate format.
@@ -150,6 +422,65 @@ func ValidateBitrate(bitrate string) bool {
 	return bitratePattern.MatchString(bitrate)
 }
 
+// ParseBitrate parses an iperf3 bitrate of the form #[KMG][/#] into bits per
+// second and a burst packet count. An empty bitrate returns zeros.
+func ParseBitrate(bitrate string) (uint64, int, error) {
+	if bitrate == "" {
+		return 0, 0, nil
+	}
+
+	if !ValidateBitrate(bitrate) {
+		return 0, 0, fmt.Errorf("invalid bitrate format: %s", bitrate)
+	}
+
+	value, burstPart, hasBurst := strings.Cut(bitrate, "/")
+
+	multiplier := 1.0
+
+	switch value[len(value)-1] {
+	case 'K':
+		multiplier = 1e3
+	case 'M':
+		multiplier = 1e6
+	case 'G':
+		multiplier = 1e9
+	}
+
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid bitrate format: %s", bitrate)
+	}
+
+	var burst int
+	if hasBurst {
+		burst, err = strconv.Atoi(burstPart)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid bitrate burst: %s", bitrate)
+		}
+	}
+
+	return uint64(number * multiplier), burst, nil
+}
+
+// RequestedBitrate returns the bitrate in bits per second a test of protocol is limited to,
+// including the iperf3 default for UDP, or zero when it is unlimited or invalid.
+func RequestedBitrate(protocol, bitrate string) uint64 {
+	rate, _, err := ParseBitrate(bitrate)
+	if err != nil {
+		return 0
+	}
+
+	if bitrate == "" && protocol == "udp" {
+		return defaultUDPBitrate
+	}
+
+	return rate
+}
+
 // Run executes an iperf3 test with the given configuration and returns the parsed results.
 // This is a convenience function that uses the DefaultRunner.
 func Run(ctx context.Context, cfg Config) Result {
@@ -169,6 +500,7 @@ func (r *DefaultRunner) Run(ctx context.Context, cfg Config) Result {
 	// Validate bitrate if provided
 	if cfg.Bitrate != "" && !ValidateBitrate(cfg.Bitrate) {
 		result.Error = fmt.Errorf("invalid bitrate format: %s", cfg.Bitrate)
+		result.FailureReason = FailureUnknown
 		cfg.Logger.Error("Invalid bitrate format", "bitrate", cfg.Bitrate)
 
 		return result
@@ -190,8 +522,27 @@ func (r *DefaultRunner) Run(ctx context.Context, cfg Config) Result {
 		iperfArgs = append(iperfArgs, "-R")
 	}
 
-	if cfg.Protocol == "udp" {
+	if cfg.Bidir {
+		iperfArgs = append(iperfArgs, "--bidir")
+	}
+
+	if cfg.OnInterval != nil {
+		iperfArgs = append(iperfArgs, "--json-stream")
+	}
+
+	if cfg.GetServerOutput {
+		iperfArgs = append(iperfArgs, "--get-server-output")
+	}
+
+	if cfg.Parallel > 1 {
+		iperfArgs = append(iperfArgs, "-P", strconv.Itoa(cfg.Parallel))
+	}
+
+	switch cfg.Protocol {
+	case "udp":
 		iperfArgs = append(iperfArgs, "-u")
+	case "sctp":
+		iperfArgs = append(iperfArgs, "--sctp")
 	}
 
 	// Apply bitrate:
@@ -211,13 +562,27 @@ func (r *DefaultRunner) Run(ctx context.Context, cfg Config) Result {
 	}
 
 	// Create command with context
-	// #nosec G204 - GetIperfCmd returns a hardcoded string and iperfArgs are validated
-	var cmd *exec.Cmd
+	// #nosec G204 - the binary comes from the exporter configuration and iperfArgs are validated
+	var (
+		cmd    *exec.Cmd
+		runCtx context.Context
+	)
+
 	if ctx != nil {
+		// Interrupt iperf3 shortly before the deadline, it then still prints the results so far
+		var (
+			cancel context.CancelFunc
+			grace  time.Duration
+		)
+
+		runCtx, cancel, grace = interruptContext(ctx, cfg.GracePeriod)
+		defer cancel()
+
 		// Use the mockable execCommandContext for context-aware commands
-		cmd = execCommandContext(ctx, GetIperfCmd(), iperfArgs...)
+		cmd = execCommandContext(runCtx, cfg.binary(), iperfArgs...)
+		defer gracefulStop(cmd, grace)()
 	} else {
-		cmd = execCommand(GetIperfCmd(), iperfArgs...)
+		cmd = execCommand(cfg.binary(), iperfArgs...)
 	}
 
 	var stderr bytes.Buffer
@@ -229,50 +594,179 @@ func (r *DefaultRunner) Run(ctx context.Context, cfg Config) Result {
 		"port", cfg.Port,
 		"period", cfg.Period,
 		"reverse", cfg.ReverseMode,
+		"bidir", cfg.Bidir,
 		"protocol", cfg.Protocol,
 		"bitrate", cfg.Bitrate,
 		"bind", cfg.Bind,
+		"parallel", cfg.Parallel,
+		"stream", cfg.OnInterval != nil,
+		"get_server_output", cfg.GetServerOutput,
 	)
 
-	out, err := cmd.Output()
-	if err != nil {
-		stderrOutput := stderr.String()
+	var (
+		out []byte
+		err error
+	)
+
+	if cfg.OnInterval != nil {
+		out, err = runStream(cmd, cfg.OnInterval)
+	} else {
+		out, err = cmd.Output()
+	}
+
+	result.Output = &RawOutput{
+		Command: cmd.Args,
+		Stdout:  out,
+		Stderr:  stderr.Bytes(),
+		// -1 when the process could not be started or was killed
+		ExitCode: -1,
+	}
+
+	if cmd.ProcessState != nil {
+		result.Output.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	// Keep what iperf3 measured until it was interrupted at the timeout
+	if err != nil && runCtx != nil && runCtx.Err() != nil && parsePartialOutput(cfg, &result, out) {
+		cfg.Logger.Warn("iperf3 was interrupted at the timeout, exporting partial results",
+			"target", cfg.Target,
+			"port", cfg.Port,
+			"sent_seconds", result.SentSeconds,
+			"received_seconds", result.ReceivedSeconds,
+		)
+
+		return result
+	}
+
+	if !parseOutput(runCtx, cfg, &result, "iperf3", out, stderr.String(), err) {
+		return result
+	}
+
+	// Enhanced logging with protocol-specific metrics
+	switch cfg.Protocol {
+	case "udp":
+		cfg.Logger.Debug("iperf3 UDP test completed successfully",
+			"target", cfg.Target,
+			"sent_bps", result.SentBitsPerSecond,
+			"received_bps", result.ReceivedBitsPerSecond,
+			"sent_jitter", result.SentJitter,
+			"received_jitter", result.ReceivedJitter,
+			"sent_lost_percent", result.SentLostPercent,
+			"received_lost_percent", result.ReceivedLostPercent,
+		)
+	case "sctp":
+		cfg.Logger.Debug("iperf3 SCTP test completed successfully",
+			"target", cfg.Target,
+			"sent_bps", result.SentBitsPerSecond,
+			"received_bps", result.ReceivedBitsPerSecond,
+		)
+	default:
+		cfg.Logger.Debug("iperf3 TCP test completed successfully",
+			"target", cfg.Target,
+			"sent_bps", result.SentBitsPerSecond,
+			"received_bps", result.ReceivedBitsPerSecond,
+			"retransmits", result.Retransmits,
+			"tcp_info", result.TCPInfo != nil,
+		)
+	}
+
+	return result
+}
+
+// parsePartialOutput fills result from the output iperf3 printed after it was interrupted,
+// and reports whether it measured anything before that.
+func parsePartialOutput(cfg Config, result *Result, out []byte) bool {
+	var raw rawResult
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return false
+	}
+
+	if raw.End.SumSent.Seconds <= 0 && raw.End.SumReceived.Seconds <= 0 && raw.End.Sum.Seconds <= 0 {
+		return false
+	}
+
+	result.Success = true
+	result.Truncated = true
+	parseResult(&raw, cfg, result)
+
+	return true
+}
+
+// parseOutput fills result from the iperf3 JSON output of a test run with the command name
+// that exited with runErr, and reports whether the test succeeded.
+func parseOutput(ctx context.Context, cfg Config, result *Result, name string, out []byte, stderrOutput string, runErr error) bool {
+	if runErr != nil {
+		// iperf3 still prints its JSON document with an "error" field when -J is used
+		var raw rawResult
+		if jsonErr := json.Unmarshal(out, &raw); jsonErr == nil && raw.Error != "" {
+			cfg.Logger.Error("Failed to run "+name,
+				"err", runErr,
+				"iperf3_error", raw.Error,
+			)
+
+			result.Error = fmt.Errorf("%s execution failed: %w: %s", name, runErr, raw.Error)
+			result.FailureReason = ClassifyFailure(raw.Error, contextErr(ctx))
+
+			return false
+		}
+
 		if stderrOutput != "" {
-			cfg.Logger.Error("Failed to run iperf3",
-				"err", err,
+			cfg.Logger.Error("Failed to run "+name,
+				"err", runErr,
 				"stderr", stderrOutput,
 			)
 
-			result.Error = fmt.Errorf("iperf3 execution failed: %w: %s", err, stderrOutput)
+			result.Error = fmt.Errorf("%s execution failed: %w: %s", name, runErr, stderrOutput)
 		} else {
-			cfg.Logger.Error("Failed to run iperf3",
-				"err", err,
+			cfg.Logger.Error("Failed to run "+name,
+				"err", runErr,
 			)
 
-			result.Error = fmt.Errorf("iperf3 execution failed: %w", err)
+			result.Error = fmt.Errorf("%s execution failed: %w", name, runErr)
 		}
 
-		return result
+		result.FailureReason = ClassifyFailure(stderrOutput, contextErr(ctx))
+
+		return false
 	}
 
 	// Parse the JSON output
 	var raw rawResult
 	if err := json.Unmarshal(out, &raw); err != nil {
-		cfg.Logger.Error("Failed to parse iperf3 result",
+		cfg.Logger.Error("Failed to parse "+name+" result",
 			"err", err,
 		)
 
-		result.Error = fmt.Errorf("failed to parse iperf3 result: %w", err)
+		result.Error = fmt.Errorf("failed to parse %s result: %w", name, err)
+		result.FailureReason = FailureParse
 
-		return result
+		return false
+	}
+
+	// iperf3 may report a failed test in the JSON output while exiting with 0
+	if raw.Error != "" {
+		cfg.Logger.Error(name+" reported an error",
+			"iperf3_error", raw.Error,
+		)
+
+		result.Error = fmt.Errorf("%s reported an error: %s", name, raw.Error)
+		result.FailureReason = ClassifyFailure(raw.Error, contextErr(ctx))
+
+		return false
 	}
 
 	// Set success flag and process metrics
 	result.Success = true
+	parseResult(&raw, cfg, result)
+
+	return true
+}
 
+// parseResult fills result with the metrics of a successful test from its iperf3 JSON document.
+func parseResult(raw *rawResult, cfg Config, result *Result) {
 	// Handle different metrics based on the protocol
-	if cfg.Protocol == "tcp" {
-		// TCP Mode - use TCP-specific JSON fields
+	if cfg.Protocol == "tcp" || cfg.Protocol == "sctp" {
+		// TCP and SCTP Mode - use the stream oriented sum_sent/sum_received JSON fields
 		result.SentSeconds = raw.End.SumSent.Seconds
 		result.SentBytes = raw.End.SumSent.Bytes
 		result.SentBitsPerSecond = raw.End.SumSent.BitsPerSecond
@@ -280,20 +774,62 @@ func (r *DefaultRunner) Run(ctx context.Context, cfg Config) Result {
 		result.ReceivedBytes = raw.End.SumReceived.Bytes
 		result.ReceivedBitsPerSecond = raw.End.SumReceived.BitsPerSecond
 		result.Retransmits = raw.End.SumSent.Retransmits
+
+		for _, stream := range raw.End.Streams {
+			// In bidirectional mode the server to client streams are reported separately
+			if cfg.Bidir && !stream.Sender.Sender {
+				continue
+			}
+
+			result.Streams = append(result.Streams, StreamResult{
+				Socket:                stream.Sender.Socket,
+				SentBytes:             stream.Sender.Bytes,
+				SentBitsPerSecond:     stream.Sender.BitsPerSecond,
+				ReceivedBytes:         stream.Receiver.Bytes,
+				ReceivedBitsPerSecond: stream.Receiver.BitsPerSecond,
+				Retransmits:           stream.Sender.Retransmits,
+			})
+		}
 	} else {
-		// UDP Mode - use UDP-specific JSON fields from streams[0].udp and sum
-		// Add boundary check before accessing Streams[0]
+		// UDP Mode - use UDP-specific JSON fields from streams[].udp and sum
 		if len(raw.End.Streams) > 0 {
-			// Common metrics using sender (streams[0].udp) data
-			result.SentSeconds = raw.End.Streams[0].UDP.Seconds
-			result.SentBytes = raw.End.Streams[0].UDP.Bytes
-			result.SentBitsPerSecond = raw.End.Streams[0].UDP.BitsPerSecond
-
-			// UDP-specific metrics from streams[0].udp
-			result.SentPackets = raw.End.Streams[0].UDP.Packets
-			result.SentJitter = raw.End.Streams[0].UDP.JitterMs
-			result.SentLostPackets = raw.End.Streams[0].UDP.LostPackets
-			result.SentLostPercent = raw.End.Streams[0].UDP.LostPercent
+			// Common metrics using sender (streams[].udp) data, aggregated over parallel streams
+			var jitterSum float64
+
+			for _, stream := range raw.End.Streams {
+				// In bidirectional mode the server to client streams are reported separately
+				if cfg.Bidir && !stream.UDP.Sender {
+					continue
+				}
+
+				if stream.UDP.Seconds > result.SentSeconds {
+					result.SentSeconds = stream.UDP.Seconds
+				}
+
+				result.SentBytes += stream.UDP.Bytes
+				result.SentBitsPerSecond += stream.UDP.BitsPerSecond
+
+				// UDP-specific metrics from streams[].udp
+				result.SentPackets += stream.UDP.Packets
+				result.SentLostPackets += stream.UDP.LostPackets
+				jitterSum += stream.UDP.JitterMs
+
+				result.Streams = append(result.Streams, StreamResult{
+					Socket:            stream.UDP.Socket,
+					SentBytes:         stream.UDP.Bytes,
+					SentBitsPerSecond: stream.UDP.BitsPerSecond,
+				})
+			}
+
+			if len(result.Streams) > 0 {
+				result.SentJitter = jitterSum / float64(len(result.Streams))
+			}
+
+			if len(raw.End.Streams) == 1 {
+				result.SentLostPercent = raw.End.Streams[0].UDP.LostPercent
+			} else if result.SentPackets > 0 {
+				result.SentLostPercent = result.SentLostPackets / result.SentPackets * 100
+			}
 		} else {
 			cfg.Logger.Warn("UDP mode: no streams found in iperf3 result")
 		}
@@ -320,32 +856,123 @@ func (r *DefaultRunner) Run(ctx context.Context, cfg Config) Result {
 		}
 	}
 
-	// Enhanced logging with protocol-specific metrics
-	if cfg.Protocol == "udp" {
-		cfg.Logger.Debug("iperf3 UDP test completed successfully",
-			"target", cfg.Target,
-			"sent_bps", result.SentBitsPerSecond,
-			"received_bps", result.ReceivedBitsPerSecond,
-			"sent_jitter", result.SentJitter,
-			"received_jitter", result.ReceivedJitter,
-			"sent_lost_percent", result.SentLostPercent,
-			"received_lost_percent", result.ReceivedLostPercent,
-		)
-	} else {
-		cfg.Logger.Debug("iperf3 TCP test completed successfully",
-			"target", cfg.Target,
-			"sent_bps", result.SentBitsPerSecond,
-			"received_bps", result.ReceivedBitsPerSecond,
-			"retransmits", result.Retransmits,
-		)
+	// In bidirectional mode the reverse direction is reported in separate sums
+	if cfg.Bidir {
+		reverse := raw.End.SumSentBidirReverse
+		reverseReceived := raw.End.SumReceivedBidirReverse
+
+		result.BidirReverse = &DirectionResult{
+			SentSeconds:           reverse.Seconds,
+			SentBytes:             reverse.Bytes,
+			SentBitsPerSecond:     reverse.BitsPerSecond,
+			ReceivedSeconds:       reverseReceived.Seconds,
+			ReceivedBytes:         reverseReceived.Bytes,
+			ReceivedBitsPerSecond: reverseReceived.BitsPerSecond,
+			Retransmits:           reverse.Retransmits,
+		}
 	}
 
-	return result
+	// Keep the per-interval samples so the distribution can be exported
+	result.Intervals = make([]Interval, 0, len(raw.Intervals))
+	for _, interval := range raw.Intervals {
+		result.Intervals = append(result.Intervals, interval.interval())
+	}
+
+	if cfg.Protocol == "tcp" {
+		result.TCPInfo = parseTCPInfo(raw, result.Intervals)
+	}
+
+	if cpu := raw.End.CPUUtilizationPercent; cpu != nil {
+		result.CPUUtilization = &CPUUtilization{
+			HostTotal:    cpu.HostTotal,
+			HostUser:     cpu.HostUser,
+			HostSystem:   cpu.HostSystem,
+			RemoteTotal:  cpu.RemoteTotal,
+			RemoteUser:   cpu.RemoteUser,
+			RemoteSystem: cpu.RemoteSystem,
+		}
+	}
+
+	result.Start = raw.startInfo()
+
+	if raw.ServerOutputJSON != nil {
+		result.ServerOutput = parseServerOutput(raw.ServerOutputJSON, cfg, result.CPUUtilization.swap())
+	}
+}
+
+// startInfo returns the start section of the output, or nil when iperf3 did not report one.
+func (raw *rawResult) startInfo() *StartInfo {
+	start := raw.Start
+	if start.Version == "" && start.Timestamp.Timesecs == 0 && len(start.Connected) == 0 {
+		return nil
+	}
+
+	info := &StartInfo{
+		Version:    start.Version,
+		SystemInfo: start.SystemInfo,
+		Cookie:     start.Cookie,
+		NumStreams: start.TestStart.NumStreams,
+		BlockSize:  start.TestStart.BlockSize,
+		Omit:       start.TestStart.Omit,
+		Duration:   start.TestStart.Duration,
+		Reverse:    start.TestStart.Reverse != 0,
+		TOS:        start.TestStart.TOS,
+	}
+
+	if start.Timestamp.Timesecs > 0 {
+		info.Timestamp = time.Unix(start.Timestamp.Timesecs, 0)
+	}
+
+	for _, conn := range start.Connected {
+		info.Connections = append(info.Connections, Connection{
+			LocalHost:  conn.LocalHost,
+			LocalPort:  conn.LocalPort,
+			RemoteHost: conn.RemoteHost,
+			RemotePort: conn.RemotePort,
+		})
+	}
+
+	return info
 }
 
-// CheckIperf3Exists verifies that the iperf3 command exists and is executable.
-func CheckIperf3Exists() error {
-	_, err := lookPath(GetIperfCmd())
+// NewServerCmd returns the command that runs an iperf3 server from binary on port, optionally bound to bind.
+// The server flushes its output after every line so that it can be followed while it runs.
+// When logFile is set the server writes its results as JSON to that file instead of stdout.
+func NewServerCmd(ctx context.Context, binary string, port int, bind string, logFile string) *exec.Cmd {
+	args := []string{"-s", "-p", strconv.Itoa(port), "--forceflush"}
+	if bind != "" {
+		args = append(args, "-B", bind)
+	}
+
+	if logFile != "" {
+		args = append(args, "-J", "--logfile", logFile)
+	}
+
+	// #nosec G204 - the binary comes from the exporter configuration and args are validated
+	return execCommandContext(ctx, BinaryOrDefault(binary), args...)
+}
+
+// contextErr returns the error of ctx, tolerating a nil context.
+func contextErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+
+	return ctx.Err()
+}
+
+// CheckIperf3Exists verifies that every given iperf3 binary exists and is executable.
+// The platform default iperf3 command is checked when no binary is given.
+func CheckIperf3Exists(binaries ...string) error {
+	if len(binaries) == 0 {
+		binaries = []string{GetIperfCmd()}
+	}
+
+	for _, binary := range binaries {
+		if _, err := lookPath(BinaryOrDefault(binary)); err != nil {
+			return fmt.Errorf("iperf3 binary %q: %w", BinaryOrDefault(binary), err)
+		}
+	}
 
-	return err
+	return nil
 }
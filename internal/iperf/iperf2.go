@@ -0,0 +1,382 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Tool names, used to select the tool a target is tested with.
+const (
+	// ToolIperf3 tests against an iperf3 server, the default.
+	ToolIperf3 = "iperf3"
+	// ToolIperf2 tests against an iperf2 server, see Iperf2Runner.
+	ToolIperf2 = "iperf2"
+)
+
+// Iperf2Runner implements Runner by running the iperf2 binary with CSV output (-y C).
+// iperf2 reports a single measurement for TCP tests, which is used for both the sent and
+// the received side. For UDP tests the received side, jitter and loss are taken from the
+// server report.
+type Iperf2Runner struct {
+	Logger *slog.Logger
+}
+
+// NewIperf2Runner creates a new Iperf2Runner.
+func NewIperf2Runner(logger *slog.Logger) *Iperf2Runner {
+	return &Iperf2Runner{
+		Logger: logger,
+	}
+}
+
+// Iperf2BinaryOrDefault returns binary, or the platform default iperf2 command when it is empty.
+func Iperf2BinaryOrDefault(binary string) string {
+	if binary == "" {
+		return GetIperf2Cmd()
+	}
+
+	return binary
+}
+
+// CheckIperf2Exists checks that the iperf2 binaries exist, the default one when none is given.
+func CheckIperf2Exists(binaries ...string) error {
+	if len(binaries) == 0 {
+		binaries = []string{GetIperf2Cmd()}
+	}
+
+	for _, binary := range binaries {
+		if _, err := lookPath(Iperf2BinaryOrDefault(binary)); err != nil {
+			return fmt.Errorf("iperf2 binary %q: %w", Iperf2BinaryOrDefault(binary), err)
+		}
+	}
+
+	return nil
+}
+
+// iperf2Report is a single line of iperf2 CSV output.
+type iperf2Report struct {
+	ID            int
+	Start         float64
+	End           float64
+	Bytes         float64
+	BitsPerSecond float64
+	// UDP receiver report fields, only set when UDP is true
+	UDP         bool
+	JitterMs    float64
+	LostPackets float64
+	Packets     float64
+	LostPercent float64
+}
+
+// Run executes an iperf2 test with the given configuration and returns the parsed results.
+func (r *Iperf2Runner) Run(ctx context.Context, cfg Config) Result {
+	result := Result{
+		Success:  false,
+		Protocol: cfg.Protocol,
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = r.Logger
+	}
+
+	if cfg.Protocol != "tcp" && cfg.Protocol != "udp" {
+		result.Error = fmt.Errorf("iperf2 does not support protocol %q", cfg.Protocol)
+		result.FailureReason = FailureUnknown
+
+		return result
+	}
+
+	if cfg.Bitrate != "" && !ValidateBitrate(cfg.Bitrate) {
+		result.Error = fmt.Errorf("invalid bitrate format: %s", cfg.Bitrate)
+		result.FailureReason = FailureUnknown
+
+		return result
+	}
+
+	args := []string{
+		"-y", "C",
+		"-t", strconv.FormatFloat(cfg.Period.Seconds(), 'f', 0, 64),
+		"-c", cfg.Target,
+		"-p", strconv.Itoa(cfg.Port),
+	}
+
+	if cfg.Bind != "" {
+		args = append(args, "-B", cfg.Bind)
+	}
+
+	if cfg.ReverseMode {
+		args = append(args, "-R")
+	}
+
+	if cfg.Parallel > 1 {
+		args = append(args, "-P", strconv.Itoa(cfg.Parallel))
+	}
+
+	if cfg.Protocol == "udp" {
+		args = append(args, "-u")
+
+		// Like iperf3, iperf2 defaults to 1 Mbit/sec for UDP
+		if cfg.Bitrate == "" {
+			args = append(args, "-b", "1M")
+		}
+	}
+
+	if cfg.Bitrate != "" {
+		args = append(args, "-b", cfg.Bitrate)
+	}
+
+	binary := Iperf2BinaryOrDefault(cfg.Binary)
+
+	// #nosec G204 - the binary comes from the exporter configuration and args are validated
+	var cmd *exec.Cmd
+	if ctx != nil {
+		cmd = execCommandContext(ctx, binary, args...)
+	} else {
+		cmd = execCommand(binary, args...)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	cfg.Logger.Debug("Running iperf2 command",
+		"target", cfg.Target,
+		"port", cfg.Port,
+		"period", cfg.Period,
+		"reverse", cfg.ReverseMode,
+		"protocol", cfg.Protocol,
+		"bitrate", cfg.Bitrate,
+		"bind", cfg.Bind,
+		"parallel", cfg.Parallel,
+	)
+
+	out, err := cmd.Output()
+
+	result.Output = &RawOutput{
+		Command:  cmd.Args,
+		Stdout:   out,
+		Stderr:   stderr.Bytes(),
+		ExitCode: -1,
+	}
+
+	if cmd.ProcessState != nil {
+		result.Output.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	reports := parseIperf2CSV(out)
+
+	// iperf2 reports most errors on stderr, some of them while exiting with 0
+	if err != nil || len(reports) == 0 {
+		message := strings.TrimSpace(stderr.String())
+
+		switch {
+		case err != nil && message != "":
+			result.Error = fmt.Errorf("iperf2 execution failed: %w: %s", err, message)
+		case err != nil:
+			result.Error = fmt.Errorf("iperf2 execution failed: %w", err)
+		case message != "":
+			result.Error = fmt.Errorf("iperf2 reported no results: %s", message)
+		default:
+			result.Error = fmt.Errorf("iperf2 reported no results")
+		}
+
+		result.FailureReason = ClassifyFailure(message, contextErr(ctx))
+		if err == nil && message == "" {
+			result.FailureReason = FailureParse
+		}
+
+		cfg.Logger.Error("Failed to run iperf2", "err", result.Error)
+
+		return result
+	}
+
+	result.Success = true
+	fillIperf2Result(&result, reports, cfg)
+
+	cfg.Logger.Debug("iperf2 test completed successfully",
+		"target", cfg.Target,
+		"sent_bps", result.SentBitsPerSecond,
+		"received_bps", result.ReceivedBitsPerSecond,
+	)
+
+	return result
+}
+
+// parseIperf2CSV parses iperf2 -y C output, skipping any line that is not a report.
+// A report has 9 fields, UDP receiver reports have 14:
+// timestamp,source_address,source_port,destination_address,destination_port,id,interval,bytes,bits_per_second
+// followed by jitter_ms,lost_packets,packets,lost_percent,out_of_order_packets.
+func parseIperf2CSV(out []byte) []iperf2Report {
+	var reports []iperf2Report
+
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 9 {
+			continue
+		}
+
+		start, end, ok := strings.Cut(fields[6], "-")
+		if !ok {
+			continue
+		}
+
+		var (
+			report iperf2Report
+			errs   [5]error
+		)
+
+		report.ID, errs[0] = strconv.Atoi(fields[5])
+		report.Start, errs[1] = strconv.ParseFloat(start, 64)
+		report.End, errs[2] = strconv.ParseFloat(end, 64)
+		report.Bytes, errs[3] = strconv.ParseFloat(fields[7], 64)
+		report.BitsPerSecond, errs[4] = strconv.ParseFloat(fields[8], 64)
+
+		if !allNil(errs[:]) {
+			continue
+		}
+
+		if len(fields) >= 13 {
+			var udpErrs [4]error
+
+			report.JitterMs, udpErrs[0] = strconv.ParseFloat(fields[9], 64)
+			report.LostPackets, udpErrs[1] = strconv.ParseFloat(fields[10], 64)
+			report.Packets, udpErrs[2] = strconv.ParseFloat(fields[11], 64)
+			report.LostPercent, udpErrs[3] = strconv.ParseFloat(fields[12], 64)
+			report.UDP = allNil(udpErrs[:])
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+// allNil reports whether every error is nil.
+func allNil(errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fillIperf2Result maps the reports of an iperf2 test to a Result. With parallel streams
+// iperf2 adds a sum report with ID -1, which is used for the totals when present.
+func fillIperf2Result(result *Result, reports []iperf2Report, cfg Config) {
+	var (
+		sender, receiver             iperf2Report
+		hasSenderSum, hasReceiverSum bool
+		streams                      = map[int]*StreamResult{}
+		streamIDs                    []int
+	)
+
+	add := func(total *iperf2Report, r iperf2Report) {
+		total.Bytes += r.Bytes
+		total.BitsPerSecond += r.BitsPerSecond
+		total.End = max(total.End, r.End-r.Start)
+		total.LostPackets += r.LostPackets
+		total.Packets += r.Packets
+		// The jitter of the worst stream
+		total.JitterMs = max(total.JitterMs, r.JitterMs)
+	}
+
+	for _, r := range reports {
+		if r.ID == -1 {
+			if r.UDP {
+				receiver, hasReceiverSum = r, true
+				receiver.End -= r.Start
+			} else {
+				sender, hasSenderSum = r, true
+				sender.End -= r.Start
+			}
+
+			continue
+		}
+
+		stream, ok := streams[r.ID]
+		if !ok {
+			stream = &StreamResult{Socket: r.ID}
+			streams[r.ID] = stream
+			streamIDs = append(streamIDs, r.ID)
+		}
+
+		if r.UDP {
+			stream.ReceivedBytes, stream.ReceivedBitsPerSecond = r.Bytes, r.BitsPerSecond
+			if !hasReceiverSum {
+				add(&receiver, r)
+			}
+		} else {
+			stream.SentBytes, stream.SentBitsPerSecond = r.Bytes, r.BitsPerSecond
+			if !hasSenderSum {
+				add(&sender, r)
+			}
+		}
+	}
+
+	result.SentBytes = sender.Bytes
+	result.SentBitsPerSecond = sender.BitsPerSecond
+	result.SentSeconds = sender.End
+
+	if cfg.Protocol == "tcp" || !hasUDPReport(reports) {
+		// A single TCP measurement covers both sides
+		result.ReceivedBytes = sender.Bytes
+		result.ReceivedBitsPerSecond = sender.BitsPerSecond
+		result.ReceivedSeconds = sender.End
+
+		for _, stream := range streams {
+			stream.ReceivedBytes, stream.ReceivedBitsPerSecond = stream.SentBytes, stream.SentBitsPerSecond
+		}
+	} else {
+		result.ReceivedBytes = receiver.Bytes
+		result.ReceivedBitsPerSecond = receiver.BitsPerSecond
+		result.ReceivedSeconds = receiver.End
+		result.ReceivedPackets = receiver.Packets
+		result.ReceivedLostPackets = receiver.LostPackets
+		result.ReceivedJitter = receiver.JitterMs
+
+		if receiver.Packets > 0 {
+			result.ReceivedLostPercent = receiver.LostPackets / receiver.Packets * 100
+		}
+
+		// Like the iperf3 client output, the sender side reports what the receiver measured
+		result.SentPackets = receiver.Packets
+		result.SentJitter = result.ReceivedJitter
+		result.SentLostPackets = result.ReceivedLostPackets
+		result.SentLostPercent = result.ReceivedLostPercent
+	}
+
+	if len(streamIDs) > 1 {
+		for _, id := range streamIDs {
+			result.Streams = append(result.Streams, *streams[id])
+		}
+	}
+}
+
+// hasUDPReport reports whether any of the reports is a UDP receiver report.
+func hasUDPReport(reports []iperf2Report) bool {
+	for _, r := range reports {
+		if r.UDP {
+			return true
+		}
+	}
+
+	return false
+}
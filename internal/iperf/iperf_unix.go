@@ -20,3 +20,8 @@ package iperf
 func GetIperfCmd() string {
 	return "iperf3"
 }
+
+// GetIperf2Cmd returns the command name for iperf2 on Unix-like systems.
+func GetIperf2Cmd() string {
+	return "iperf"
+}
@@ -20,3 +20,8 @@ package iperf
 func GetIperfCmd() string {
 	return "iperf3.exe"
 }
+
+// GetIperf2Cmd returns the command name for iperf2 on Windows systems.
+func GetIperf2Cmd() string {
+	return "iperf.exe"
+}
@@ -0,0 +1,682 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runner names, used to select a Runner implementation per target.
+const (
+	// RunnerExec runs the iperf3 binary, see DefaultRunner.
+	RunnerExec = "exec"
+	// RunnerNative speaks the iperf3 protocol directly, see NativeRunner.
+	RunnerNative = "native"
+	// RunnerReplay returns recorded iperf3 output instead of running tests, see ReplayRunner.
+	RunnerReplay = "replay"
+	// RunnerCommand runs a configured command that prints iperf3 JSON, see CommandRunner.
+	RunnerCommand = "command"
+)
+
+// iperf3 control channel states, sent as a single signed byte.
+const (
+	stateTestStart       int8 = 1
+	stateTestRunning     int8 = 2
+	stateTestEnd         int8 = 4
+	stateParamExchange   int8 = 9
+	stateCreateStreams   int8 = 10
+	stateServerTerminate int8 = 11
+	stateClientTerminate int8 = 12
+	stateExchangeResults int8 = 13
+	stateDisplayResults  int8 = 14
+	stateIperfStart      int8 = 15
+	stateIperfDone       int8 = 16
+	stateAccessDenied    int8 = -1
+	stateServerError     int8 = -2
+)
+
+const (
+	// cookieSize is the size of the test cookie including its trailing NUL.
+	cookieSize = 37
+	// cookieChars are the characters iperf3 uses for test cookies.
+	cookieChars = "abcdefghijklmnopqrstuvwxyz234567"
+	// defaultTCPBlockSize is the iperf3 default block size for TCP tests.
+	defaultTCPBlockSize = 128 * 1024
+	// defaultUDPBlockSize is the iperf3 default datagram size for UDP tests.
+	defaultUDPBlockSize = 1460
+	// defaultUDPBitrate is the iperf3 default bitrate for UDP tests.
+	defaultUDPBitrate = 1000 * 1000
+	// maxControlMessageSize bounds the JSON documents read from the control channel.
+	maxControlMessageSize = 16 * 1024 * 1024
+	// nativeClientVersion is the iperf3 version the native client announces.
+	nativeClientVersion = "3.16"
+)
+
+// UDP stream handshake messages. The legacy values are accepted by every
+// iperf3 server release, newer servers may answer with either reply.
+const (
+	udpConnectMsg         uint32 = 123456789
+	udpConnectReply       uint32 = 0x39383736
+	udpConnectReplyLegacy uint32 = 987654321
+)
+
+// NativeRunner is a Runner that speaks the iperf3 control protocol directly,
+// so that no iperf3 binary is needed on the exporter host. It supports TCP and
+// UDP tests in normal and reverse mode with parallel streams.
+type NativeRunner struct {
+	Logger *slog.Logger
+}
+
+// NewNativeRunner creates a new native iperf3 protocol runner.
+func NewNativeRunner(logger *slog.Logger) Runner {
+	return &NativeRunner{
+		Logger: logger,
+	}
+}
+
+// nativeParams is the test parameter document sent to the server.
+type nativeParams struct {
+	TCP           bool   `json:"tcp,omitempty"`
+	UDP           bool   `json:"udp,omitempty"`
+	Omit          int    `json:"omit"`
+	Time          int    `json:"time"`
+	Num           int    `json:"num"`
+	BlockCount    int    `json:"blockcount"`
+	Parallel      int    `json:"parallel"`
+	Reverse       bool   `json:"reverse,omitempty"`
+	Len           int    `json:"len"`
+	Bandwidth     uint64 `json:"bandwidth,omitempty"`
+	Burst         int    `json:"burst,omitempty"`
+	PacingTimer   int    `json:"pacing_timer"`
+	ClientVersion string `json:"client_version"`
+	// Ask the server to send its JSON output with its results
+	GetServerOutput int  `json:"get_server_output,omitempty"`
+	JSONOutput      bool `json:"json_output,omitempty"`
+}
+
+// nativeResults is the results document exchanged with the server at the end of a test.
+type nativeResults struct {
+	CPUUtilTotal         float64               `json:"cpu_util_total"`
+	CPUUtilUser          float64               `json:"cpu_util_user"`
+	CPUUtilSystem        float64               `json:"cpu_util_system"`
+	SenderHasRetransmits int                   `json:"sender_has_retransmits"`
+	Streams              []nativeStreamResults `json:"streams"`
+	// Only sent by the server when its output was requested
+	ServerOutputJSON *rawResult `json:"server_output_json,omitempty"`
+}
+
+// nativeStreamResults is the per-stream part of nativeResults. Jitter is in seconds.
+type nativeStreamResults struct {
+	ID             int     `json:"id"`
+	Bytes          float64 `json:"bytes"`
+	Retransmits    float64 `json:"retransmits"`
+	Jitter         float64 `json:"jitter"`
+	Errors         float64 `json:"errors"`
+	OmittedErrors  float64 `json:"omitted_errors"`
+	Packets        float64 `json:"packets"`
+	OmittedPackets float64 `json:"omitted_packets"`
+	StartTime      float64 `json:"start_time"`
+	EndTime        float64 `json:"end_time"`
+}
+
+// nativeTest holds the state of a single test run by the NativeRunner.
+type nativeTest struct {
+	cfg       Config
+	logger    *slog.Logger
+	dialer    net.Dialer
+	address   string
+	cookie    []byte
+	blockSize int
+	rate      uint64
+	burst     int
+	ctrl      net.Conn
+	intervals []Interval
+	started   time.Time
+	ended     time.Time
+	// CPU time of the exporter process when the transfer started, see processCPUTime
+	cpuUser   time.Duration
+	cpuSystem time.Duration
+	cpuOK     bool
+
+	// streams is guarded by mu since the connections are closed from the context's AfterFunc
+	mu      sync.Mutex
+	streams []*nativeStream
+
+	// stopStreams stops the data transfer and waits for the stream goroutines
+	stopStreams func()
+}
+
+// Run executes an iperf3 test over the native protocol implementation and returns the parsed results.
+func (r *NativeRunner) Run(ctx context.Context, cfg Config) Result {
+	result := Result{
+		Success:  false,
+		Protocol: cfg.Protocol,
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = r.Logger
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	t, err := newNativeTest(cfg)
+	if err != nil {
+		cfg.Logger.Error("Invalid native iperf3 test configuration", "err", err)
+
+		result.Error = err
+		result.FailureReason = FailureUnknown
+
+		return result
+	}
+
+	cfg.Logger.Debug("Running native iperf3 test",
+		"target", cfg.Target,
+		"port", cfg.Port,
+		"period", cfg.Period,
+		"reverse", cfg.ReverseMode,
+		"protocol", cfg.Protocol,
+		"bitrate", cfg.Bitrate,
+		"bind", cfg.Bind,
+		"parallel", cfg.Parallel,
+	)
+
+	server, err := t.run(ctx)
+	if err != nil {
+		cfg.Logger.Error("Failed to run native iperf3 test", "err", err)
+
+		result.Error = fmt.Errorf("native iperf3 test failed: %w", err)
+		result.FailureReason = ClassifyFailure(err.Error(), ctx.Err())
+
+		return result
+	}
+
+	t.fillResult(&result, server)
+	result.Success = true
+
+	cfg.Logger.Debug("Native iperf3 test completed successfully",
+		"target", cfg.Target,
+		"sent_bps", result.SentBitsPerSecond,
+		"received_bps", result.ReceivedBitsPerSecond,
+	)
+
+	return result
+}
+
+// newNativeTest validates cfg and prepares a test.
+func newNativeTest(cfg Config) (*nativeTest, error) {
+	if cfg.Protocol != "tcp" && cfg.Protocol != "udp" {
+		return nil, fmt.Errorf("native runner does not support protocol %q", cfg.Protocol)
+	}
+
+	if cfg.Bidir {
+		return nil, errors.New("native runner does not support bidirectional mode")
+	}
+
+	rate, burst, err := ParseBitrate(cfg.Bitrate)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &nativeTest{
+		cfg:       cfg,
+		logger:    cfg.Logger,
+		address:   net.JoinHostPort(cfg.Target, strconv.Itoa(cfg.Port)),
+		blockSize: defaultTCPBlockSize,
+		rate:      rate,
+		burst:     burst,
+		// Replaced once the transfer has started
+		stopStreams: func() {},
+	}
+
+	if cfg.Protocol == "udp" {
+		t.blockSize = defaultUDPBlockSize
+		if t.rate == 0 && cfg.Bitrate == "" {
+			t.rate = defaultUDPBitrate
+		}
+	}
+
+	if cfg.Bind != "" {
+		ip := net.ParseIP(cfg.Bind)
+		if ip == nil {
+			return nil, fmt.Errorf("native runner requires an IP address to bind to, got %q", cfg.Bind)
+		}
+
+		t.dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	t.cookie, err = newCookie()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// newCookie generates a random test cookie in the format used by iperf3.
+func newCookie() ([]byte, error) {
+	random := make([]byte, cookieSize-1)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate test cookie: %w", err)
+	}
+
+	cookie := make([]byte, cookieSize)
+	for i, b := range random {
+		cookie[i] = cookieChars[int(b)%len(cookieChars)]
+	}
+
+	return cookie, nil
+}
+
+// run performs the control channel exchange and returns the results reported by the server.
+func (t *nativeTest) run(ctx context.Context) (*nativeResults, error) {
+	ctrl, err := t.dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to server: %w", err)
+	}
+
+	t.ctrl = ctrl
+	defer t.close()
+
+	// Unblock any pending I/O once the context expires
+	stop := context.AfterFunc(ctx, t.close)
+	defer stop()
+
+	if _, err := ctrl.Write(t.cookie); err != nil {
+		return nil, fmt.Errorf("unable to send cookie to server: %w", err)
+	}
+
+	for {
+		state, err := t.readState()
+		if err != nil {
+			return nil, err
+		}
+
+		switch state {
+		case stateParamExchange:
+			if err := t.sendParams(); err != nil {
+				return nil, err
+			}
+		case stateCreateStreams:
+			if err := t.createStreams(ctx); err != nil {
+				return nil, err
+			}
+		case stateTestStart, stateIperfStart:
+			// Nothing to prepare, streams were created on CREATE_STREAMS
+		case stateTestRunning:
+			if err := t.transfer(ctx); err != nil {
+				return nil, err
+			}
+		case stateExchangeResults:
+			server, err := t.exchangeResults()
+			if err != nil {
+				return nil, err
+			}
+
+			return server, t.finish()
+		case stateAccessDenied:
+			return nil, errors.New("the server is busy running a test. try again later")
+		case stateServerError:
+			return nil, t.readServerError()
+		case stateServerTerminate:
+			return nil, errors.New("the server has terminated")
+		default:
+			return nil, fmt.Errorf("unexpected state %d from server", state)
+		}
+	}
+}
+
+// finish waits for the server to display its results and ends the test.
+func (t *nativeTest) finish() error {
+	state, err := t.readState()
+	if err != nil {
+		return err
+	}
+
+	if state != stateDisplayResults {
+		return fmt.Errorf("unexpected state %d from server after results exchange", state)
+	}
+
+	return t.writeState(stateIperfDone)
+}
+
+// close closes the control and data connections.
+func (t *nativeTest) close() {
+	if t.ctrl != nil {
+		_ = t.ctrl.Close()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range t.streams {
+		_ = s.conn.Close()
+	}
+}
+
+// readState reads a single control channel state.
+func (t *nativeTest) readState() (int8, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(t.ctrl, b[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, errors.New("control socket has closed unexpectedly")
+		}
+
+		return 0, fmt.Errorf("unable to read from control socket: %w", err)
+	}
+
+	return int8(b[0]), nil
+}
+
+// writeState writes a single control channel state.
+func (t *nativeTest) writeState(state int8) error {
+	if _, err := t.ctrl.Write([]byte{byte(state)}); err != nil {
+		return fmt.Errorf("unable to write to control socket: %w", err)
+	}
+
+	return nil
+}
+
+// readServerError reads the error codes following a SERVER_ERROR state.
+func (t *nativeTest) readServerError() error {
+	var codes [8]byte
+	if _, err := io.ReadFull(t.ctrl, codes[:]); err != nil {
+		return errors.New("server error: unable to read error codes")
+	}
+
+	return fmt.Errorf("server error: iperf3 error %d (errno %d)",
+		int32(binary.BigEndian.Uint32(codes[0:4])),
+		int32(binary.BigEndian.Uint32(codes[4:8])),
+	)
+}
+
+// writeJSON writes a length prefixed JSON document to the control channel.
+func (t *nativeTest) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+
+	if _, err := t.ctrl.Write(buf); err != nil {
+		return fmt.Errorf("unable to write to control socket: %w", err)
+	}
+
+	return nil
+}
+
+// readJSON reads a length prefixed JSON document from the control channel.
+func (t *nativeTest) readJSON(v any) error {
+	var size [4]byte
+	if _, err := io.ReadFull(t.ctrl, size[:]); err != nil {
+		return fmt.Errorf("unable to read from control socket: %w", err)
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxControlMessageSize {
+		return fmt.Errorf("control message of %d bytes exceeds the limit", n)
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(t.ctrl, data); err != nil {
+		return fmt.Errorf("unable to read from control socket: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse control message: %w", err)
+	}
+
+	return nil
+}
+
+// sendParams sends the test parameters to the server.
+func (t *nativeTest) sendParams() error {
+	params := nativeParams{
+		TCP:           t.cfg.Protocol == "tcp",
+		UDP:           t.cfg.Protocol == "udp",
+		Time:          int(math.Round(t.cfg.Period.Seconds())),
+		Parallel:      max(t.cfg.Parallel, 1),
+		Reverse:       t.cfg.ReverseMode,
+		Len:           t.blockSize,
+		Bandwidth:     t.rate,
+		Burst:         t.burst,
+		PacingTimer:   1000,
+		ClientVersion: nativeClientVersion,
+	}
+
+	if t.cfg.GetServerOutput {
+		params.GetServerOutput = 1
+		params.JSONOutput = true
+	}
+
+	return t.writeJSON(params)
+}
+
+// exchangeResults sends the client results and reads the results of the server.
+func (t *nativeTest) exchangeResults() (*nativeResults, error) {
+	// In reverse mode the client is still receiving, stop the readers first
+	t.stopStreams()
+
+	// The receivers of reverse mode are joined now, a stream that failed fails the test
+	for _, s := range t.streams {
+		if s.err != nil {
+			return nil, s.err
+		}
+	}
+
+	local := nativeResults{
+		SenderHasRetransmits: 0,
+	}
+
+	if cpu, ok := t.cpuUtilization(); ok {
+		local.CPUUtilTotal = cpu.HostTotal
+		local.CPUUtilUser = cpu.HostUser
+		local.CPUUtilSystem = cpu.HostSystem
+	}
+
+	for _, s := range t.streams {
+		local.Streams = append(local.Streams, s.results(t.started, t.ended))
+	}
+
+	if err := t.writeJSON(local); err != nil {
+		return nil, err
+	}
+
+	var server nativeResults
+	if err := t.readJSON(&server); err != nil {
+		return nil, err
+	}
+
+	return &server, nil
+}
+
+// fillResult maps the local and server side measurements to a Result.
+func (t *nativeTest) fillResult(result *Result, server *nativeResults) {
+	remote := make(map[int]nativeStreamResults, len(server.Streams))
+	for _, s := range server.Streams {
+		remote[s.ID] = s
+	}
+
+	duration := t.ended.Sub(t.started).Seconds()
+
+	var (
+		remoteSeconds float64
+		jitterSum     float64
+	)
+
+	for _, s := range t.streams {
+		local := s.results(t.started, t.ended)
+		peer := remote[s.id]
+
+		sender, receiver := local, peer
+		if t.cfg.ReverseMode {
+			sender, receiver = peer, local
+		}
+
+		remoteSeconds = max(remoteSeconds, peer.EndTime-peer.StartTime)
+		jitterSum += receiver.Jitter
+
+		result.SentBytes += sender.Bytes
+		result.ReceivedBytes += receiver.Bytes
+		result.SentPackets += sender.Packets
+		result.ReceivedPackets += receiver.Packets
+		result.ReceivedLostPackets += receiver.Errors
+
+		result.Streams = append(result.Streams, StreamResult{
+			Socket:                s.id,
+			SentBytes:             sender.Bytes,
+			SentBitsPerSecond:     bitsPerSecond(sender.Bytes, duration),
+			ReceivedBytes:         receiver.Bytes,
+			ReceivedBitsPerSecond: bitsPerSecond(receiver.Bytes, duration),
+		})
+	}
+
+	// The local side measured the whole test, the remote side reports its own timing
+	result.SentSeconds = duration
+	result.ReceivedSeconds = duration
+
+	if t.cfg.ReverseMode {
+		result.SentSeconds = remoteSeconds
+	} else {
+		result.ReceivedSeconds = remoteSeconds
+	}
+
+	result.SentBitsPerSecond = bitsPerSecond(result.SentBytes, result.SentSeconds)
+	result.ReceivedBitsPerSecond = bitsPerSecond(result.ReceivedBytes, result.ReceivedSeconds)
+
+	if t.cfg.Protocol == "udp" && len(t.streams) > 0 {
+		// Jitter and loss are measured by the receiver and reported for both sides,
+		// matching the iperf3 client JSON output
+		result.ReceivedJitter = jitterSum / float64(len(t.streams)) * 1000
+		result.SentJitter = result.ReceivedJitter
+		result.SentLostPackets = result.ReceivedLostPackets
+
+		if result.ReceivedPackets > 0 {
+			result.ReceivedLostPercent = result.ReceivedLostPackets / result.ReceivedPackets * 100
+		}
+
+		if result.SentPackets > 0 {
+			result.SentLostPercent = result.SentLostPackets / result.SentPackets * 100
+		}
+	}
+
+	result.Intervals = t.intervals
+	result.Start = t.startInfo()
+
+	// The host side is only known when the CPU time of the process could be read
+	if cpu, ok := t.cpuUtilization(); ok {
+		cpu.RemoteTotal = server.CPUUtilTotal
+		cpu.RemoteUser = server.CPUUtilUser
+		cpu.RemoteSystem = server.CPUUtilSystem
+		result.CPUUtilization = cpu
+	}
+
+	if server.ServerOutputJSON != nil {
+		result.ServerOutput = parseServerOutput(server.ServerOutputJSON, t.cfg, &CPUUtilization{
+			HostTotal:  server.CPUUtilTotal,
+			HostUser:   server.CPUUtilUser,
+			HostSystem: server.CPUUtilSystem,
+		})
+	}
+}
+
+// startInfo returns the connections and the parameters of the test, like the start section of iperf3.
+func (t *nativeTest) startInfo() *StartInfo {
+	info := &StartInfo{
+		Cookie:     strings.TrimRight(string(t.cookie), "\x00"),
+		Timestamp:  t.started,
+		NumStreams: len(t.streams),
+		BlockSize:  t.blockSize,
+		Duration:   int(t.cfg.Period.Seconds()),
+		Reverse:    t.cfg.ReverseMode,
+	}
+
+	for _, s := range t.streams {
+		localHost, localPort := splitAddr(s.conn.LocalAddr())
+		remoteHost, remotePort := splitAddr(s.conn.RemoteAddr())
+
+		info.Connections = append(info.Connections, Connection{
+			LocalHost:  localHost,
+			LocalPort:  localPort,
+			RemoteHost: remoteHost,
+			RemotePort: remotePort,
+		})
+	}
+
+	return info
+}
+
+// splitAddr returns the host and port of a network address.
+func splitAddr(addr net.Addr) (string, int) {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String(), 0
+	}
+
+	p, _ := strconv.Atoi(port)
+
+	return host, p
+}
+
+// cpuUtilization returns the CPU utilization of the exporter process since the transfer started.
+// Like iperf3 it covers the whole process, so tests running concurrently raise each other's value.
+func (t *nativeTest) cpuUtilization() (*CPUUtilization, bool) {
+	user, system, ok := processCPUTime()
+	if !ok || !t.cpuOK {
+		return nil, false
+	}
+
+	wall := t.ended.Sub(t.started)
+	if wall <= 0 {
+		return nil, false
+	}
+
+	percent := func(d time.Duration) float64 {
+		return float64(d) / float64(wall) * 100
+	}
+
+	cpuUser, cpuSystem := user-t.cpuUser, system-t.cpuSystem
+
+	return &CPUUtilization{
+		HostTotal:  percent(cpuUser + cpuSystem),
+		HostUser:   percent(cpuUser),
+		HostSystem: percent(cpuSystem),
+	}, true
+}
+
+// bitsPerSecond converts a byte count over a duration in seconds to a bitrate.
+func bitsPerSecond(bytes, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+
+	return bytes * 8 / seconds
+}
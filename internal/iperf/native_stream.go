@@ -0,0 +1,372 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// udpHeaderSize is the size of the sequence header of an iperf3 UDP datagram
+	// (seconds, microseconds and a 32-bit packet counter).
+	udpHeaderSize = 12
+	// maxUDPDatagramSize is the largest datagram the native receiver accepts.
+	maxUDPDatagramSize = 64 * 1024
+	// intervalLength is the reporting interval of the native runner, matching iperf3's default.
+	intervalLength = time.Second
+)
+
+// nativeStream is a single data connection of a native test.
+type nativeStream struct {
+	id      int
+	udp     bool
+	conn    net.Conn
+	bytes   atomic.Int64
+	stopped atomic.Bool
+	err     error
+
+	// Packet accounting, written only by the stream's own goroutine
+	packets     int64
+	errors      int64
+	jitter      float64
+	prevTransit float64
+}
+
+// streamID returns the iperf3 stream id of the i-th stream. iperf3 numbers the
+// first stream 1 and the following ones from 3, and the server matches result
+// entries by id, so the same numbering is used here.
+func streamID(i int) int {
+	if i == 0 {
+		return 1
+	}
+
+	return i + 2
+}
+
+// createStreams opens the data connections requested by the server.
+func (t *nativeTest) createStreams(ctx context.Context) error {
+	for i := range max(t.cfg.Parallel, 1) {
+		s := &nativeStream{
+			id:  streamID(i),
+			udp: t.cfg.Protocol == "udp",
+		}
+
+		var err error
+		if s.udp {
+			s.conn, err = t.dialUDP(ctx)
+		} else {
+			s.conn, err = t.dialTCP(ctx)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		t.streams = append(t.streams, s)
+		t.mu.Unlock()
+	}
+
+	return nil
+}
+
+// dialTCP opens a TCP data connection and identifies it with the test cookie.
+func (t *nativeTest) dialTCP(ctx context.Context) (net.Conn, error) {
+	conn, err := t.dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create stream: %w", err)
+	}
+
+	if _, err := conn.Write(t.cookie); err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("unable to create stream: %w", err)
+	}
+
+	return conn, nil
+}
+
+// dialUDP opens a UDP data connection and performs the iperf3 connect handshake.
+func (t *nativeTest) dialUDP(ctx context.Context) (net.Conn, error) {
+	dialer := t.dialer
+	if addr, ok := t.dialer.LocalAddr.(*net.TCPAddr); ok {
+		dialer.LocalAddr = &net.UDPAddr{IP: addr.IP}
+	}
+
+	conn, err := dialer.DialContext(ctx, "udp", t.address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create stream: %w", err)
+	}
+
+	// iperf3 sends the connect message in host byte order
+	var msg [4]byte
+	binary.LittleEndian.PutUint32(msg[:], udpConnectMsg)
+
+	if _, err := conn.Write(msg[:]); err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("unable to create stream: %w", err)
+	}
+
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("unable to create stream: no UDP connect reply: %w", err)
+	}
+
+	if !validUDPConnectReply(reply) {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("unable to create stream: unexpected UDP connect reply %x", reply)
+	}
+
+	return conn, nil
+}
+
+// validUDPConnectReply reports whether reply is a UDP connect reply in either byte order.
+func validUDPConnectReply(reply [4]byte) bool {
+	for _, v := range []uint32{binary.LittleEndian.Uint32(reply[:]), binary.BigEndian.Uint32(reply[:])} {
+		if v == udpConnectReply || v == udpConnectReplyLegacy {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transfer runs the data transfer for the configured period and signals the end of the test.
+func (t *nativeTest) transfer(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	t.started = time.Now()
+	t.cpuUser, t.cpuSystem, t.cpuOK = processCPUTime()
+
+	for _, s := range t.streams {
+		wg.Add(1)
+
+		go func(s *nativeStream) {
+			defer wg.Done()
+
+			if t.cfg.ReverseMode {
+				s.receive()
+			} else {
+				s.send(t.started, t.blockSize, t.rate, t.burst)
+			}
+		}(s)
+	}
+
+	t.stopStreams = func() {
+		for _, s := range t.streams {
+			s.stopped.Store(true)
+			_ = s.conn.SetDeadline(time.Now())
+		}
+
+		wg.Wait()
+	}
+
+	ticker := time.NewTicker(intervalLength)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(t.cfg.Period)
+	defer timer.Stop()
+
+	last := t.started
+
+	var lastBytes int64
+
+	sample := func(now time.Time) {
+		total := t.totalBytes()
+		interval := newInterval(t.started, last, now, total-lastBytes)
+		t.intervals = append(t.intervals, interval)
+		last, lastBytes = now, total
+
+		if t.cfg.OnInterval != nil {
+			t.cfg.OnInterval(interval)
+		}
+	}
+
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			sample(now)
+		case <-timer.C:
+			running = false
+		}
+	}
+
+	// The sender stops before announcing the end, a receiver keeps draining
+	// until the server has closed its side
+	if !t.cfg.ReverseMode {
+		t.stopStreams()
+	}
+
+	t.ended = time.Now()
+	if t.ended.Sub(last) > intervalLength/10 {
+		sample(t.ended)
+	}
+
+	// The receivers are still running in reverse mode, their errors can only be read
+	// once stopStreams joined them
+	if !t.cfg.ReverseMode {
+		for _, s := range t.streams {
+			if s.err != nil {
+				return s.err
+			}
+		}
+	}
+
+	return t.writeState(stateTestEnd)
+}
+
+// totalBytes returns the bytes transferred so far over all streams.
+func (t *nativeTest) totalBytes() int64 {
+	var total int64
+	for _, s := range t.streams {
+		total += s.bytes.Load()
+	}
+
+	return total
+}
+
+// newInterval builds an Interval between from and to relative to start.
+func newInterval(start, from, to time.Time, bytes int64) Interval {
+	seconds := to.Sub(from).Seconds()
+
+	return Interval{
+		Start:         from.Sub(start).Seconds(),
+		End:           to.Sub(start).Seconds(),
+		Seconds:       seconds,
+		Bytes:         float64(bytes),
+		BitsPerSecond: bitsPerSecond(float64(bytes), seconds),
+	}
+}
+
+// send writes blocks to the stream until it is stopped, pacing to rate when set.
+func (s *nativeStream) send(start time.Time, blockSize int, rate uint64, burst int) {
+	buf := make([]byte, blockSize)
+	burst = max(burst, 1)
+
+	for n := 0; !s.stopped.Load(); n++ {
+		if s.udp {
+			now := time.Now()
+			s.packets++
+			binary.BigEndian.PutUint32(buf[0:4], uint32(now.Unix()))
+			binary.BigEndian.PutUint32(buf[4:8], uint32(now.Nanosecond()/1000))
+			binary.BigEndian.PutUint32(buf[8:12], uint32(s.packets))
+		}
+
+		written, err := s.conn.Write(buf)
+		s.bytes.Add(int64(written))
+
+		if err != nil {
+			if !s.stopped.Load() {
+				s.err = fmt.Errorf("unable to write to stream socket: %w", err)
+			}
+
+			return
+		}
+
+		if rate > 0 && n%burst == burst-1 {
+			s.pace(start, rate)
+		}
+	}
+}
+
+// pace sleeps until the stream is back on the schedule given by rate.
+func (s *nativeStream) pace(start time.Time, rate uint64) {
+	due := time.Duration(float64(s.bytes.Load()) * 8 / float64(rate) * float64(time.Second))
+	if wait := due - time.Since(start); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// receive reads from the stream until it is stopped or closed by the server.
+func (s *nativeStream) receive() {
+	buf := make([]byte, maxUDPDatagramSize)
+
+	for !s.stopped.Load() {
+		n, err := s.conn.Read(buf)
+		s.bytes.Add(int64(n))
+
+		if s.udp && n >= udpHeaderSize {
+			s.account(buf[:n], time.Now())
+		}
+
+		if err != nil {
+			if !s.stopped.Load() && !errors.Is(err, io.EOF) {
+				s.err = fmt.Errorf("unable to read from stream socket: %w", err)
+			}
+
+			return
+		}
+	}
+}
+
+// account updates the loss and jitter statistics from a received UDP datagram,
+// following the same algorithm as iperf3 (RFC 1889 interarrival jitter).
+func (s *nativeStream) account(datagram []byte, arrival time.Time) {
+	sec := binary.BigEndian.Uint32(datagram[0:4])
+	usec := binary.BigEndian.Uint32(datagram[4:8])
+	pcount := int64(binary.BigEndian.Uint32(datagram[8:12]))
+
+	switch {
+	case pcount >= s.packets+1:
+		// Packets in between were lost, or are out of order and will be subtracted later
+		if pcount > s.packets+1 {
+			s.errors += pcount - s.packets - 1
+		}
+
+		s.packets = pcount
+	default:
+		// An out of order packet that was counted as lost before
+		if s.errors > 0 {
+			s.errors--
+		}
+	}
+
+	sent := float64(sec) + float64(usec)/1e6
+	transit := float64(arrival.UnixNano())/1e9 - sent
+
+	if s.prevTransit != 0 {
+		d := math.Abs(transit - s.prevTransit)
+		s.jitter += (d - s.jitter) / 16
+	}
+
+	s.prevTransit = transit
+}
+
+// results returns the per-stream results document of the local side.
+func (s *nativeStream) results(started, ended time.Time) nativeStreamResults {
+	return nativeStreamResults{
+		ID:        s.id,
+		Bytes:     float64(s.bytes.Load()),
+		Jitter:    s.jitter,
+		Errors:    float64(s.errors),
+		Packets:   float64(s.packets),
+		StartTime: 0,
+		EndTime:   ended.Sub(started).Seconds(),
+	}
+}
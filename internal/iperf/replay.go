@@ -0,0 +1,166 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReplayRunner implements Runner by returning recorded iperf3 JSON output as if the tests ran live.
+// Path is a single file with the output of one or more tests, such as the -J output of a client or
+// the log of iperf3 -s -J --logfile, or a directory of such files with the .json extension. Every
+// run returns the next recorded test, starting over after the last one. The recordings are read on
+// every run, so they can be changed while the exporter is running.
+type ReplayRunner struct {
+	Path   string
+	Logger *slog.Logger
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewReplayRunner creates a new ReplayRunner of the recordings in path.
+func NewReplayRunner(path string, logger *slog.Logger) *ReplayRunner {
+	return &ReplayRunner{
+		Path:   path,
+		Logger: logger,
+	}
+}
+
+// Run returns the next recorded test. The protocol and direction are taken from the recording,
+// the rest of cfg only selects the metrics, like for a live test.
+func (r *ReplayRunner) Run(ctx context.Context, cfg Config) Result {
+	result := Result{
+		Success:  false,
+		Protocol: cfg.Protocol,
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = r.Logger
+	}
+
+	if ctx != nil && ctx.Err() != nil {
+		result.Error = fmt.Errorf("iperf3 replay cancelled: %w", ctx.Err())
+		result.FailureReason = FailureTimeout
+
+		return result
+	}
+
+	recordings, err := LoadRecordings(r.Path)
+	if err == nil && len(recordings) == 0 {
+		err = fmt.Errorf("no iperf3 results found in %s", r.Path)
+	}
+
+	if err != nil {
+		cfg.Logger.Error("Failed to load iperf3 recordings", "path", r.Path, "err", err)
+
+		result.Error = err
+		result.FailureReason = FailureParse
+
+		return result
+	}
+
+	r.mu.Lock()
+	index := r.next % len(recordings)
+	r.next = index + 1
+	r.mu.Unlock()
+
+	var raw rawResult
+	if err := json.Unmarshal(recordings[index], &raw); err != nil {
+		cfg.Logger.Error("Failed to parse recorded iperf3 result", "path", r.Path, "index", index, "err", err)
+
+		result.Error = fmt.Errorf("failed to parse iperf3 result: %w", err)
+		result.FailureReason = FailureParse
+
+		return result
+	}
+
+	// Parse the recording the way it was run
+	if protocol := strings.ToLower(raw.Start.TestStart.Protocol); protocol != "" {
+		cfg.Protocol = protocol
+		result.Protocol = protocol
+	}
+
+	if raw.Start.TestStart.Protocol != "" {
+		cfg.ReverseMode = raw.Start.TestStart.Reverse != 0
+		cfg.Bidir = raw.Start.TestStart.Bidir != 0
+	}
+
+	cfg.Logger.Debug("Replaying recorded iperf3 result", "path", r.Path, "index", index, "target", cfg.Target)
+
+	if raw.Error != "" {
+		result.Error = fmt.Errorf("iperf3 reported an error: %s", raw.Error)
+		result.FailureReason = ClassifyFailure(raw.Error, nil)
+
+		return result
+	}
+
+	if cfg.OnInterval != nil {
+		for _, entry := range raw.Intervals {
+			cfg.OnInterval(entry.interval())
+		}
+	}
+
+	result.Success = true
+	parseResult(&raw, cfg, &result)
+
+	return result
+}
+
+// LoadRecordings returns the recorded iperf3 JSON documents in path, a file or a directory
+// of .json files, in the order of the file names and of the documents in each file.
+func LoadRecordings(path string) ([][]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(files)
+	}
+
+	var recordings [][]byte
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		objects, rest := splitJSONObjects(data)
+		if len(strings.TrimSpace(string(rest))) > 0 {
+			return nil, errors.New("incomplete JSON document at the end of " + file)
+		}
+
+		recordings = append(recordings, objects...)
+	}
+
+	return recordings, nil
+}
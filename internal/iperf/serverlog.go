@@ -0,0 +1,254 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxServerLogObjectSize bounds the size of a single JSON test object in a server log.
+// A log that keeps growing without ever completing an object is not iperf3 JSON output.
+const maxServerLogObjectSize = 16 * 1024 * 1024
+
+// ServerResult represents a test served by an iperf3 server, as recorded in its JSON log.
+type ServerResult struct {
+	Result
+	// Client is the address of the client that ran the test
+	Client string
+	// Port is the port the server accepted the test on
+	Port int
+	// Reverse is set when the client requested the server to send (-R)
+	Reverse bool
+	// Timestamp is the time the test started
+	Timestamp time.Time
+}
+
+// ParseServerResult parses a single JSON test object written by iperf3 -s -J.
+// The received values describe what the receiving side of the test saw,
+// which is the server itself unless the client ran in reverse mode.
+func ParseServerResult(data []byte, logger *slog.Logger) (ServerResult, error) {
+	var raw rawResult
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return ServerResult{}, fmt.Errorf("failed to parse iperf3 server result: %w", err)
+	}
+
+	result := ServerResult{
+		Client:  raw.Start.AcceptedConnection.Host,
+		Reverse: raw.Start.TestStart.Reverse != 0,
+	}
+
+	if raw.Start.Timestamp.Timesecs > 0 {
+		result.Timestamp = time.Unix(raw.Start.Timestamp.Timesecs, 0)
+	}
+
+	if len(raw.Start.Connected) > 0 {
+		result.Port = raw.Start.Connected[0].LocalPort
+		if result.Client == "" {
+			result.Client = raw.Start.Connected[0].RemoteHost
+		}
+	}
+
+	result.Protocol = strings.ToLower(raw.Start.TestStart.Protocol)
+
+	if raw.Error != "" {
+		result.Error = fmt.Errorf("iperf3 reported an error: %s", raw.Error)
+		result.FailureReason = ClassifyFailure(raw.Error, nil)
+
+		return result, nil
+	}
+
+	result.Success = true
+	parseResult(&raw, Config{
+		Protocol: result.Protocol,
+		Bidir:    raw.Start.TestStart.Bidir != 0,
+		Logger:   logger,
+	}, &result.Result)
+
+	return result, nil
+}
+
+// ServerLogTailer follows the JSON log file of an iperf3 server (iperf3 -s -J --logfile)
+// and parses every test object appended to it. Truncated and rotated files are
+// followed from their beginning.
+type ServerLogTailer struct {
+	path         string
+	pollInterval time.Duration
+	logger       *slog.Logger
+
+	file   *os.File
+	offset int64
+	buf    []byte
+}
+
+// NewServerLogTailer creates a tailer for the server log at path, checked for new data every pollInterval.
+func NewServerLogTailer(path string, pollInterval time.Duration, logger *slog.Logger) *ServerLogTailer {
+	return &ServerLogTailer{
+		path:         path,
+		pollInterval: pollInterval,
+		logger:       logger.With("log_file", path),
+	}
+}
+
+// Run reads the log until ctx is done, calling fn for every parsed test.
+// The log is read from its beginning so that the last result of every client is restored.
+func (t *ServerLogTailer) Run(ctx context.Context, fn func(ServerResult)) {
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	defer t.close()
+
+	for {
+		t.poll(fn)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the data appended to the log since the last call.
+func (t *ServerLogTailer) poll(fn func(ServerResult)) {
+	if t.file == nil {
+		file, err := os.Open(t.path)
+		if err != nil {
+			// The server may not have written the log yet
+			t.logger.Debug("Unable to open iperf3 server log", "err", err)
+			return
+		}
+
+		t.file, t.offset, t.buf = file, 0, nil
+	}
+
+	current, err := t.file.Stat()
+	if err != nil {
+		t.logger.Warn("Unable to stat iperf3 server log", "err", err)
+		t.close()
+
+		return
+	}
+
+	if current.Size() < t.offset {
+		t.logger.Info("iperf3 server log was truncated, reading it from the start")
+
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			t.logger.Warn("Unable to rewind iperf3 server log", "err", err)
+			t.close()
+
+			return
+		}
+
+		t.offset, t.buf = 0, nil
+	}
+
+	t.read(fn)
+
+	// A rotated log is replaced by a new file, which is opened on the next poll
+	// once the old one has been read to its end
+	if info, err := os.Stat(t.path); err != nil || !os.SameFile(info, current) {
+		t.logger.Info("iperf3 server log was rotated, reopening it")
+		t.close()
+	}
+}
+
+// read consumes the unread part of the log and calls fn for every complete test object.
+func (t *ServerLogTailer) read(fn func(ServerResult)) {
+	data, err := io.ReadAll(t.file)
+	t.offset += int64(len(data))
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.logger.Warn("Unable to read iperf3 server log", "err", err)
+	}
+
+	objects, rest := splitJSONObjects(append(t.buf, data...))
+	t.buf = append([]byte(nil), rest...)
+
+	if len(t.buf) > maxServerLogObjectSize {
+		t.logger.Warn("Discarding oversized data in iperf3 server log", "bytes", len(t.buf))
+		t.buf = nil
+	}
+
+	for _, object := range objects {
+		result, err := ParseServerResult(object, t.logger)
+		if err != nil {
+			t.logger.Warn("Unable to parse iperf3 server log entry", "err", err)
+			continue
+		}
+
+		fn(result)
+	}
+}
+
+// close closes the log file so that it is reopened on the next poll.
+func (t *ServerLogTailer) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+}
+
+// splitJSONObjects splits concatenated JSON objects, returning the complete objects
+// and the remainder of an incomplete trailing one. Data outside of objects is skipped.
+func splitJSONObjects(data []byte) ([][]byte, []byte) {
+	var (
+		objects  [][]byte
+		start    = -1
+		depth    int
+		inString bool
+		escaped  bool
+	)
+
+	for i, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = start >= 0
+		case c == '{':
+			if depth == 0 {
+				start = i
+			}
+
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+			if depth == 0 {
+				objects = append(objects, data[start:i+1])
+				start = -1
+			}
+		}
+	}
+
+	if start < 0 {
+		return objects, nil
+	}
+
+	return objects, data[start:]
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import "fmt"
+
+// parseServerOutput returns the server's own view of a test from the output it sent
+// with --get-server-output. cpu is the utilization from the server's point of view as
+// reported to the client, used when the server output does not include it.
+func parseServerOutput(raw *rawResult, cfg Config, cpu *CPUUtilization) *Result {
+	server := &Result{Protocol: cfg.Protocol}
+
+	if raw.Error != "" {
+		server.Error = fmt.Errorf("iperf3 server reported an error: %s", raw.Error)
+		server.FailureReason = ClassifyFailure(raw.Error, nil)
+
+		return server
+	}
+
+	server.Success = true
+	parseResult(raw, cfg, server)
+
+	// The server sends its output before it has summarized the test,
+	// the totals then have to be taken from its intervals
+	if raw.End.SumSent.Bytes == 0 && raw.End.SumReceived.Bytes == 0 && raw.End.Sum.Bytes == 0 {
+		summarizeServerIntervals(raw, cfg, server)
+	}
+
+	if server.CPUUtilization == nil {
+		server.CPUUtilization = cpu
+	}
+
+	return server
+}
+
+// summarizeServerIntervals fills the totals of the direction the server measured from its intervals.
+// The server receives in normal mode and sends in reverse mode.
+func summarizeServerIntervals(raw *rawResult, cfg Config, server *Result) {
+	var seconds, bytes, packets, lost, jitter float64
+
+	for _, interval := range raw.Intervals {
+		if interval.Sum.Omitted {
+			continue
+		}
+
+		seconds += interval.Sum.Seconds
+		bytes += interval.Sum.Bytes
+		packets += interval.Sum.Packets
+		lost += interval.Sum.LostPackets
+		// iperf3 reports the running jitter estimate, the last one is the final value
+		jitter = interval.Sum.JitterMs
+	}
+
+	if cfg.ReverseMode {
+		server.SentSeconds = seconds
+		server.SentBytes = bytes
+		server.SentBitsPerSecond = bitsPerSecond(bytes, seconds)
+
+		return
+	}
+
+	server.ReceivedSeconds = seconds
+	server.ReceivedBytes = bytes
+	server.ReceivedBitsPerSecond = bitsPerSecond(bytes, seconds)
+
+	if cfg.Protocol == "udp" {
+		server.ReceivedPackets = packets
+		server.ReceivedJitter = jitter
+		server.ReceivedLostPackets = lost
+
+		if packets > 0 {
+			server.ReceivedLostPercent = lost / packets * 100
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os/exec"
+)
+
+// maxStreamEventSize is the largest line accepted from iperf3 --json-stream.
+// The end event of a test with many parallel streams can be large.
+const maxStreamEventSize = 16 * 1024 * 1024
+
+// streamEvent is a single line of iperf3 --json-stream output.
+type streamEvent struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// streamDocument reassembles the events of a streamed test into the document
+// that iperf3 -J prints, so that it can be parsed like a regular result.
+type streamDocument struct {
+	Start     json.RawMessage   `json:"start,omitempty"`
+	Intervals []json.RawMessage `json:"intervals"`
+	End       json.RawMessage   `json:"end,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// runStream runs cmd, which must print iperf3 --json-stream output, and calls onInterval
+// for every interval event as soon as it is printed. Like cmd.Output it returns the
+// output, reassembled into a single JSON document, and the error of the command.
+func runStream(cmd *exec.Cmd, onInterval func(Interval)) ([]byte, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var doc streamDocument
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamEventSize)
+
+	for scanner.Scan() {
+		var event streamEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Not an event, e.g. a warning printed by iperf3
+			continue
+		}
+
+		switch event.Event {
+		case "start":
+			doc.Start = event.Data
+		case "interval":
+			doc.Intervals = append(doc.Intervals, event.Data)
+
+			var entry rawIntervalEntry
+			if err := json.Unmarshal(event.Data, &entry); err == nil {
+				onInterval(entry.interval())
+			}
+		case "end":
+			doc.End = event.Data
+		case "error":
+			if err := json.Unmarshal(event.Data, &doc.Error); err != nil {
+				doc.Error = string(event.Data)
+			}
+		}
+	}
+
+	// Drain the rest of the output so that iperf3 does not block on a full pipe
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+
+	waitErr := cmd.Wait()
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if waitErr != nil {
+		return out, waitErr
+	}
+
+	return out, scanErr
+}
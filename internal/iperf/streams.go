@@ -0,0 +1,52 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+// MaxParallel is the highest number of parallel streams iperf3 accepts with -P.
+const MaxParallel = 128
+
+// ValidateParallel validates the number of parallel streams.
+// Zero means the option is not set and iperf3 uses a single stream.
+func ValidateParallel(parallel int) bool {
+	return parallel >= 0 && parallel <= MaxParallel
+}
+
+// FairnessIndex returns Jain's fairness index over the per-stream throughput.
+// In TCP mode the receiver bitrate is used since it reflects the data that was
+// actually delivered; in UDP mode only the sender bitrate is known per stream.
+// The index ranges from 1/n (one stream got everything) to 1 (perfectly fair).
+// The second return value is false when there are no streams.
+func (r Result) FairnessIndex() (float64, bool) {
+	if len(r.Streams) == 0 {
+		return 0, false
+	}
+
+	var sum, sumSquares float64
+
+	for _, stream := range r.Streams {
+		x := stream.ReceivedBitsPerSecond
+		if r.Protocol == "udp" {
+			x = stream.SentBitsPerSecond
+		}
+
+		sum += x
+		sumSquares += x * x
+	}
+
+	if sumSquares == 0 {
+		return 0, false
+	}
+
+	return (sum * sum) / (float64(len(r.Streams)) * sumSquares), true
+}
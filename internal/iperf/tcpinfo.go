@@ -0,0 +1,78 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import "time"
+
+// microseconds converts an iperf3 microsecond value to a time.Duration.
+func microseconds(us float64) time.Duration {
+	return time.Duration(us * float64(time.Microsecond))
+}
+
+// parseTCPInfo aggregates the TCP_INFO statistics of all sender streams.
+// Window sizes and the maximum RTT are the highest of any stream, the minimum
+// RTT the lowest, and the mean RTT is averaged over the streams.
+// It returns nil when iperf3 did not report TCP_INFO, so that platforms
+// without it do not export misleading zeros.
+func parseTCPInfo(raw *rawResult, intervals []Interval) *TCPInfo {
+	var (
+		info    TCPInfo
+		senders int
+		meanSum float64
+	)
+
+	for _, stream := range raw.End.Streams {
+		sender := stream.Sender
+		if sender.MaxSndCwnd == 0 && sender.MeanRTT == 0 {
+			continue
+		}
+
+		minRTT := microseconds(sender.MinRTT)
+		if senders == 0 || minRTT < info.MinRTT {
+			info.MinRTT = minRTT
+		}
+
+		info.MaxSndCwnd = max(info.MaxSndCwnd, sender.MaxSndCwnd)
+		info.MaxSndWnd = max(info.MaxSndWnd, sender.MaxSndWnd)
+		info.MaxRTT = max(info.MaxRTT, microseconds(sender.MaxRTT))
+		meanSum += sender.MeanRTT
+		senders++
+	}
+
+	if senders == 0 {
+		return nil
+	}
+
+	info.MeanRTT = microseconds(meanSum / float64(senders))
+
+	var (
+		rttVarSum time.Duration
+		samples   int
+	)
+
+	for _, interval := range intervals {
+		if interval.Omitted || interval.RTTVar == 0 {
+			continue
+		}
+
+		rttVarSum += interval.RTTVar
+		samples++
+	}
+
+	if samples > 0 {
+		info.MeanRTTVar = rttVarSum / time.Duration(samples)
+	}
+
+	return &info
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iperf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var versionPattern = regexp.MustCompile(`iperf (([0-9]+)\.([0-9]+)(?:\.([0-9]+))?\S*)`)
+
+// Version is the version of an iperf3 binary. The zero value is an unknown version.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Version as reported by the binary, e.g. "3.17.1" or "3.18+"
+	Raw string
+}
+
+// Known reports whether the version was detected.
+func (v Version) Known() bool {
+	return v.Raw != ""
+}
+
+// String returns the version as reported by the binary, or "unknown".
+func (v Version) String() string {
+	if !v.Known() {
+		return "unknown"
+	}
+
+	return v.Raw
+}
+
+// AtLeast reports whether v is the same as or newer than other.
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+
+	return v.Patch >= other.Patch
+}
+
+// Capability is an iperf3 option that is only available in newer releases.
+type Capability struct {
+	Flag  string
+	Since Version
+}
+
+// The capability table of the options used by the exporter.
+var (
+	CapabilityBidir      = Capability{Flag: "--bidir", Since: Version{Major: 3, Minor: 7, Raw: "3.7"}}
+	CapabilityJSONStream = Capability{Flag: "--json-stream", Since: Version{Major: 3, Minor: 17, Raw: "3.17"}}
+)
+
+// Capabilities lists every capability known to the exporter.
+var Capabilities = []Capability{
+	CapabilityBidir,
+	CapabilityJSONStream,
+}
+
+// Supports reports whether a binary of version v has the capability.
+// An unknown version is assumed to support everything, leaving it to iperf3 to fail.
+func (v Version) Supports(c Capability) bool {
+	return !v.Known() || v.AtLeast(c.Since)
+}
+
+// Require returns an error describing why c cannot be used with a binary of version v.
+func (v Version) Require(c Capability) error {
+	if v.Supports(c) {
+		return nil
+	}
+
+	return fmt.Errorf("%s requires iperf3 %s or newer, the iperf3 binary is version %s", c.Flag, c.Since, v)
+}
+
+// ParseVersion parses the output of iperf3 --version, e.g. "iperf 3.16 (cJSON 1.7.15)".
+func ParseVersion(output string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return Version{}, fmt.Errorf("unrecognized iperf3 version output: %q", strings.TrimSpace(output))
+	}
+
+	v := Version{Raw: match[1]}
+	v.Major, _ = strconv.Atoi(match[2])
+	v.Minor, _ = strconv.Atoi(match[3])
+
+	if match[4] != "" {
+		v.Patch, _ = strconv.Atoi(match[4])
+	}
+
+	return v, nil
+}
+
+// DetectVersion runs binary --version and returns the version of the iperf3 binary.
+func DetectVersion(binary string) (Version, error) {
+	// #nosec G204 - the binary comes from the exporter configuration
+	out, err := execCommand(BinaryOrDefault(binary), "--version").CombinedOutput()
+
+	// Some releases exit with a non-zero status after printing the version
+	v, parseErr := ParseVersion(string(out))
+	if parseErr != nil {
+		if err != nil {
+			return Version{}, fmt.Errorf("failed to run iperf3 --version: %w", err)
+		}
+
+		return Version{}, parseErr
+	}
+
+	return v, nil
+}
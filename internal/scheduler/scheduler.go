@@ -0,0 +1,235 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler decides when iperf3 tests run and limits how many run at the same time.
+package scheduler
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "iperf3"
+)
+
+// Sources of the tests, used to label the scheduler metrics.
+const (
+	// SourceTarget is a scheduled test of a configured target.
+	SourceTarget = "target"
+	// SourceProbe is a test requested on the /probe endpoint.
+	SourceProbe = "probe"
+)
+
+// Overlap policies, what happens when a target is due while its previous test still runs.
+const (
+	// OverlapSkip skips the test that is due.
+	OverlapSkip = "skip"
+	// OverlapQueue runs the test that is due once the previous one finished.
+	OverlapQueue = "queue"
+)
+
+// Metrics about the test queue.
+var (
+	QueuedTests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "scheduler", "queued_tests"),
+			Help: "Tests waiting for the destination or a free test slot.",
+		},
+		[]string{"source"},
+	)
+	RunningTests = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "scheduler", "running_tests"),
+			Help: "Tests currently running.",
+		},
+	)
+	WaitSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    prometheus.BuildFQName(namespace, "scheduler", "wait_seconds"),
+			Help:    "Time tests waited for the destination or a free test slot.",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+		},
+		[]string{"source"},
+	)
+	SkippedTests = NewTargetCounter(
+		prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, "scheduler", "skipped_tests_total"),
+			Help: "Scheduled tests of a target skipped because its previous test was still running.",
+		},
+	)
+)
+
+// TargetCounter implements the prometheus.Collector interface for a counter per target,
+// labelled with the name and the custom labels of the target. The labels differ between
+// targets, so it is an unchecked collector.
+type TargetCounter struct {
+	opts prometheus.CounterOpts
+
+	mu       sync.Mutex
+	counters map[string]prometheus.Counter
+}
+
+// NewTargetCounter creates a TargetCounter from opts, whose ConstLabels are replaced by the labels of the targets.
+func NewTargetCounter(opts prometheus.CounterOpts) *TargetCounter {
+	return &TargetCounter{
+		opts:     opts,
+		counters: make(map[string]prometheus.Counter),
+	}
+}
+
+// Inc increments the counter of the target with the given name and labels.
+func (c *TargetCounter) Inc(name string, labels prometheus.Labels) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counter, ok := c.counters[name]
+	if !ok {
+		opts := c.opts
+		opts.ConstLabels = labels
+		counter = prometheus.NewCounter(opts)
+		c.counters[name] = counter
+	}
+
+	counter.Inc()
+}
+
+// Describe implements the prometheus.Collector interface. It sends no descriptors,
+// which makes the collector unchecked.
+func (c *TargetCounter) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface.
+func (c *TargetCounter) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, counter := range c.counters {
+		counter.Collect(ch)
+	}
+}
+
+// Scheduler limits how many tests run at the same time, and runs only one test at a
+// time against every destination host:port, since an iperf3 server serves one test at a time.
+type Scheduler struct {
+	// Free test slots, nil when the number of tests is unlimited
+	slots chan struct{}
+
+	mu           sync.Mutex
+	destinations map[string]*destination
+}
+
+// destination serializes the tests against a single host:port.
+type destination struct {
+	lock chan struct{}
+	// Number of tests running or waiting, the destination is removed when it drops to zero
+	refs int
+}
+
+// New creates a Scheduler running at most maxConcurrent tests at the same time, 0 for no limit.
+func New(maxConcurrent int) *Scheduler {
+	s := &Scheduler{
+		destinations: make(map[string]*destination),
+	}
+
+	if maxConcurrent > 0 {
+		s.slots = make(chan struct{}, maxConcurrent)
+	}
+
+	return s
+}
+
+// Destination returns the destination of a test against the iperf3 server at host:port.
+func Destination(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// Acquire waits until a test against destination may run and returns a function that
+// has to be called once the test finished. It returns the error of ctx when it ends first.
+func (s *Scheduler) Acquire(ctx context.Context, dest string, source string) (func(), error) {
+	start := time.Now()
+
+	QueuedTests.WithLabelValues(source).Inc()
+	defer QueuedTests.WithLabelValues(source).Dec()
+
+	d := s.ref(dest)
+
+	// The destination first, so that waiting for it does not take up a test slot
+	select {
+	case d.lock <- struct{}{}:
+	case <-ctx.Done():
+		s.unref(dest)
+		return nil, ctx.Err()
+	}
+
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			<-d.lock
+			s.unref(dest)
+			return nil, ctx.Err()
+		}
+	}
+
+	WaitSeconds.WithLabelValues(source).Observe(time.Since(start).Seconds())
+	RunningTests.Inc()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			RunningTests.Dec()
+
+			if s.slots != nil {
+				<-s.slots
+			}
+
+			<-d.lock
+			s.unref(dest)
+		})
+	}, nil
+}
+
+// ref returns the destination, creating it when no test uses it.
+func (s *Scheduler) ref(dest string) *destination {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.destinations[dest]
+	if !ok {
+		d = &destination{lock: make(chan struct{}, 1)}
+		s.destinations[dest] = d
+	}
+
+	d.refs++
+
+	return d
+}
+
+// unref releases a reference to the destination, removing it once no test uses it.
+func (s *Scheduler) unref(dest string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.destinations[dest]
+
+	d.refs--
+	if d.refs == 0 {
+		delete(s.destinations, dest)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Splay returns the deterministic offset of the first test of a target within its interval,
+// so that targets with the same interval do not all run at once after a restart.
+func Splay(name string, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+
+	return time.Duration(h.Sum64() % uint64(interval))
+}
+
+// FirstRun returns when the first test of a target is due. A target that ran less than an
+// interval ago resumes its cadence, any other target runs after its splay.
+func FirstRun(name string, interval time.Duration, lastRun time.Time, now time.Time) time.Time {
+	if !lastRun.IsZero() {
+		next := lastRun.Add(interval)
+
+		// A last run in the future, e.g. after the clock was set back, is ignored
+		if next.After(now) && !next.After(now.Add(interval)) {
+			return next
+		}
+	}
+
+	return now.Add(Splay(name, interval))
+}
+
+// State keeps the time of the last test of every target, persisted to a file when it has a path.
+type State struct {
+	path string
+
+	mu       sync.Mutex
+	lastRuns map[string]time.Time
+}
+
+// stateFile is the content of the state file.
+type stateFile struct {
+	LastRuns map[string]time.Time `json:"last_runs"`
+}
+
+// NewState creates an empty State persisted to path, or kept in memory only when path is empty.
+func NewState(path string) *State {
+	return &State{
+		path:     path,
+		lastRuns: make(map[string]time.Time),
+	}
+}
+
+// Load reads the state file. A missing file leaves the state empty.
+func (s *State) Load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, lastRun := range file.LastRuns {
+		s.lastRuns[name] = lastRun
+	}
+
+	return nil
+}
+
+// LastRun returns the time of the last test of the target, or the zero time when it never ran.
+func (s *State) LastRun(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastRuns[name]
+}
+
+// Record sets the time of the last test of the target and writes the state file.
+func (s *State) Record(name string, lastRun time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRuns[name] = lastRun
+
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(stateFile{LastRuns: s.lastRuns})
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	// Write to a temporary file first so that a crash never leaves the state half written
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
@@ -115,9 +115,14 @@ const LandingPageTemplate = `
                 <td>Run iperf3 in reverse mode (server sends, client receives)</td>
                 <td>false</td>
             </tr>
+            <tr>
+                <td>bidir</td>
+                <td>Run iperf3 in bidirectional mode (client and server send at the same time)</td>
+                <td>false</td>
+            </tr>
             <tr>
                 <td>protocol</td>
-                <td>Run iperf3 in UDP or TCP protocol</td>
+                <td>Run iperf3 in TCP, UDP or SCTP protocol</td>
                 <td>tcp</td>
             </tr>
             <tr>
@@ -130,6 +135,21 @@ const LandingPageTemplate = `
                 <td>Duration of the iperf3 test</td>
                 <td>5s</td>
             </tr>
+            <tr>
+                <td>parallel</td>
+                <td>Number of parallel client streams to run (-P)</td>
+                <td>1</td>
+            </tr>
+            <tr>
+                <td>get_server_output</td>
+                <td>Also export the server's own view of the test (--get-server-output)</td>
+                <td>false</td>
+            </tr>
+            <tr>
+                <td>runner</td>
+                <td>Run the test with the iperf3 binary (exec), the built-in protocol client (native) or replay recorded results (replay)</td>
+                <td>exec</td>
+            </tr>
         </table>
 
         <h2>Prometheus Configuration Example</h2>
@@ -145,12 +165,18 @@ scrape_configs:
       port: ['5201']
       # Optional: enable reverse mode
       # reverse_mode: ['true']
-      # Optional: protocol to use tcp or udp default to tcp
+      # Optional: enable bidirectional mode
+      # bidir: ['true']
+      # Optional: protocol to use tcp, udp or sctp default to tcp
       # protocol: ['tcp']
       # Optional: set bitrate limit
       # bitrate: ['100M']
       # Optional: set test period
       # period: ['10s']
+      # Optional: run parallel streams
+      # parallel: ['8']
+      # Optional: export the server's view of the test
+      # get_server_output: ['true']
     relabel_configs:
       - source_labels: [__address__]
         target_label: __param_target
@@ -16,6 +16,8 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -25,12 +27,15 @@ import (
 	"strings"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/yuvaldekel/iperf3_exporter/internal/archive"
 	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
 	"github.com/yuvaldekel/iperf3_exporter/internal/config"
 	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/yuvaldekel/iperf3_exporter/internal/scheduler"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/common/version"
@@ -53,14 +58,31 @@ type Server struct {
 	logger *slog.Logger
 	server *http.Server
 	metricsCache *collector.MetricsCache
+	serverLogs *collector.ServerLogCollector
+	supervisor *supervisor
+	// Archive of raw results, nil when disabled
+	archive *archive.Archive
+	// Shared by /probe requests so that they step through the recordings
+	replay *iperf.ReplayRunner
+	// Limits the tests of targets and /probe running at the same time
+	scheduler *scheduler.Scheduler
+	// Time of the last test of every target, to resume their schedule after a restart
+	state *scheduler.State
 }
 
 // New creates a new Server.
 func New(cfg *config.Config) *Server {
+	serverLogs := collector.NewServerLogCollector()
+
 	return &Server{
 		config: cfg,
 		logger: cfg.Logger,
-		metricsCache: collector.NewMetricsCache(),
+		metricsCache: collector.NewMetricsCache(cfg.StaleIntervals),
+		serverLogs: serverLogs,
+		supervisor: newSupervisor(cfg.Servers, cfg.Iperf3Binary, serverLogs, cfg.Logger),
+		replay: iperf.NewReplayRunner(cfg.Replay, cfg.Logger),
+		scheduler: scheduler.New(cfg.MaxConcurrentTests),
+		state: scheduler.NewState(cfg.StateFile),
 	}
 }
 
@@ -76,6 +98,35 @@ func (s *Server) Start() error {
 	prometheus.MustRegister(collectors.NewBuildInfoCollector())
 	prometheus.MustRegister(collector.IperfDuration)
 	prometheus.MustRegister(collector.IperfErrors)
+	prometheus.MustRegister(collector.Failures)
+	prometheus.MustRegister(collector.BinaryInfo)
+	prometheus.MustRegister(serverUp)
+	prometheus.MustRegister(serverRestarts)
+	prometheus.MustRegister(serverTestsServed)
+	prometheus.MustRegister(s.serverLogs)
+	prometheus.MustRegister(scheduler.QueuedTests)
+	prometheus.MustRegister(scheduler.RunningTests)
+	prometheus.MustRegister(scheduler.WaitSeconds)
+	prometheus.MustRegister(scheduler.SkippedTests)
+
+	for binary, version := range s.config.Iperf3Versions {
+		collector.BinaryInfo.WithLabelValues(binary, version.String()).Set(1)
+	}
+
+	if s.config.Archive.Path != "" {
+		a, err := archive.New(s.config.Archive.Path, s.config.Archive.MaxAge, s.config.Archive.MaxBytes, s.logger)
+		if err != nil {
+			return fmt.Errorf("error opening result archive: %w", err)
+		}
+
+		s.archive = a
+		s.logger.Info("Archiving raw iperf3 results", "path", s.config.Archive.Path)
+	}
+
+	// Without its state every target starts over after its splay, which is not worth failing for
+	if err := s.state.Load(); err != nil {
+		s.logger.Warn("Unable to load the state file, targets start a new schedule", "state_file", s.config.StateFile, "err", err)
+	}
 
 	gatherers := prometheus.Gatherers{
         prometheus.DefaultGatherer,
@@ -95,6 +146,8 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/", s.indexHandler)
 	mux.HandleFunc("/health", s.healthHandler)
 	mux.HandleFunc("/ready", s.readyHandler)
+	mux.HandleFunc("GET /runs", s.runsHandler)
+	mux.HandleFunc("GET /runs/{id}", s.runHandler)
 
 	// Register pprof handlers
 	mux.HandleFunc("/debug/pprof/", http.DefaultServeMux.ServeHTTP)
@@ -104,7 +157,9 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/debug/pprof/trace", http.DefaultServeMux.ServeHTTP)
 	mux.HandleFunc("/debug/pprof/heap", http.DefaultServeMux.ServeHTTP)
 
-	// Start target collectors in the background
+	// Start managed iperf3 servers and target collectors in the background
+	s.supervisor.run(ctx, &wg)
+	s.runServerLogTailers(ctx, &wg)
 	go s.runTargetCollectors(ctx, &wg)
 	
 	listenAddr := s.config.ListenAddress
@@ -146,6 +201,20 @@ func (s *Server) Stop(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
+// runServerLogTailers ingests the JSON logs of externally run iperf3 servers until ctx is done.
+func (s *Server) runServerLogTailers(ctx context.Context, wg *sync.WaitGroup) {
+	for _, serverLog := range s.config.ServerLogs {
+		wg.Add(1)
+
+		go func(serverLog config.ServerLogConfig) {
+			defer wg.Done()
+
+			s.logger.Info("Following iperf3 server log", "log_file", serverLog.Path)
+			iperf.NewServerLogTailer(serverLog.Path, serverLog.PollInterval, s.logger).Run(ctx, s.serverLogs.Record)
+		}(serverLog)
+	}
+}
+
 // runTargetCollectors runs all configured target collectors continuously.
 // Each target collector runs on its own interval as specified in the Interval field.
 // This method runs forever in a goroutine.
@@ -170,50 +239,111 @@ func (s *Server) runTargetCollectors(ctx context.Context, wg *sync.WaitGroup) {
 
 // runTargetCollector runs a single target collector on its configured interval forever.
 func (s *Server) runTargetCollector(ctx context.Context, targetConfig collector.TargetConfig) {
-	s.logger.Info("Target collector started", "target", targetConfig.Target, "port", targetConfig.Port, "interval", targetConfig.Interval)
+	s.logger.Info("Target collector started", "name", targetConfig.Name, "target", targetConfig.Target, "port", targetConfig.Port, "interval", targetConfig.Interval)
 
-	// Create a ticker with the target's interval
-	ticker := time.NewTicker(targetConfig.Interval)
-	defer ticker.Stop()
+	// Create collector with target configuration, its results are rendered by the metrics cache
+	c := collector.NewCollector(targetConfig, s.logger)
 
-	// Create a dedicated registry for this target
-	registry := prometheus.NewRegistry()
+	if s.archive != nil {
+		c.ArchiveTo(s.archive)
+	}
 
-	// Create collector with target configuration
-	c := collector.NewCollector(targetConfig, s.logger)
-	registry.MustRegister(c)
+	// Export the current throughput while long tests are still running
+	if targetConfig.Stream {
+		c.OnInterval(func(interval iperf.Interval) {
+			s.metricsCache.UpdateCurrent(targetConfig.Name, collector.CurrentMetrics(targetConfig, interval))
+		})
+	}
+
+	// Tests are due on the schedule of the target, carrying the time they were due.
+	// busy is set while a test is due or running, for the skip overlap policy.
+	due := make(chan time.Time, 1)
+
+	var busy atomic.Bool
 
-	// Run the collector immediately on startup
-	s.executeTargetCollector(targetConfig, registry)
+	go s.tickTargetCollector(ctx, targetConfig, due, &busy)
 
-	// Run it on the interval
 	for {
 		select {
 		case <-ctx.Done():
 			s.logger.Info("Shutting down collector", "target", targetConfig.Target)
 			return 
-		case <-ticker.C:
-			s.executeTargetCollector(targetConfig, registry)
+		case scheduled := <-due:
+			s.executeTargetCollector(ctx, targetConfig, c)
+
+			if ctx.Err() == nil {
+				if err := s.state.Record(targetConfig.Name, scheduled); err != nil {
+					s.logger.Warn("Unable to write the state file", "state_file", s.config.StateFile, "err", err)
+				}
+			}
+
+			busy.Store(false)
 		}
 	}
 }
 
-// executeTargetCollector executes the collector for a single target and records metrics.
-func (s *Server) executeTargetCollector(targetConfig collector.TargetConfig, registry *prometheus.Registry) {
-	start := time.Now()
+// tickTargetCollector marks a test of the target due on every interval, starting after its
+// splay or an interval after its last test before a restart. When the previous test still
+// runs, the test is skipped or queued following the overlap policy.
+func (s *Server) tickTargetCollector(ctx context.Context, targetConfig collector.TargetConfig, due chan<- time.Time, busy *atomic.Bool) {
+	next := scheduler.FirstRun(targetConfig.Name, targetConfig.Interval, s.state.LastRun(targetConfig.Name), time.Now())
+
+	s.logger.Debug("Scheduled first test of target", "name", targetConfig.Name, "at", next)
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		scheduled := next
 
-	// Collect metrics
-	metrics, err := registry.Gather()
+		// Keep the cadence, skipping the intervals missed while the exporter was held up
+		next = next.Add(targetConfig.Interval)
+		if now := time.Now(); !next.After(now) {
+			next = next.Add((now.Sub(next)/targetConfig.Interval + 1) * targetConfig.Interval)
+		}
+		timer.Reset(time.Until(next))
+
+		queued := false
+		if s.config.OverlapPolicy == scheduler.OverlapQueue || busy.CompareAndSwap(false, true) {
+			// At most one test is queued behind the running one
+			select {
+			case due <- scheduled:
+				queued = true
+			default:
+			}
+		}
+
+		if !queued {
+			scheduler.SkippedTests.Inc(targetConfig.Name, targetConfig.ConstLabels())
+			s.logger.Warn("Skipping test, the previous test of the target is still running",
+				"name", targetConfig.Name,
+				"target", targetConfig.Target,
+				"port", targetConfig.Port,
+				"interval", targetConfig.Interval)
+		}
+	}
+}
+
+// executeTargetCollector runs a test of a single target and stores its result in the metrics cache.
+// The test waits until the scheduler lets it run.
+func (s *Server) executeTargetCollector(ctx context.Context, targetConfig collector.TargetConfig, c *collector.Collector) {
+	release, err := s.scheduler.Acquire(ctx, scheduler.Destination(targetConfig.Target, targetConfig.Port), scheduler.SourceTarget)
 	if err != nil {
-		s.logger.Error("Failed to gather metrics from collector",
-			"target", targetConfig.Target,
-			"port", targetConfig.Port,
-			"error", err)
-		collector.IperfErrors.Inc()
+		// Shutting down
 		return
 	}
+	defer release()
+
+	start := time.Now()
 
-	s.metricsCache.Update(fmt.Sprintf("%v:%v:%v", targetConfig.Target, targetConfig.Port, targetConfig.Protocol), metrics)
+	run := c.Run()
+	s.metricsCache.Update(targetConfig.Name, c, run, targetConfig.Interval)
 
 	duration := time.Since(start).Seconds()
 	collector.IperfDuration.Observe(duration)
@@ -222,7 +352,7 @@ func (s *Server) executeTargetCollector(targetConfig collector.TargetConfig, reg
 		"target", targetConfig.Target,
 		"port", targetConfig.Port,
 		"duration_seconds", duration,
-		"metric_count", len(metrics))
+		"success", run.Result.Success)
 }
 
 // probeHandler handles requests to the /probe endpoint.
@@ -269,13 +399,35 @@ func (s *Server) probeHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var bidir bool
+
+	bidirParam := r.URL.Query().Get("bidir")
+	if bidirParam != "" {
+		var err error
+
+		bidir, err = strconv.ParseBool(bidirParam)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("'bidir' parameter must be true or false (boolean): %s", err), http.StatusBadRequest)
+			collector.IperfErrors.Inc()
+
+			return
+		}
+	}
+
+	if bidir && reverseMode {
+		http.Error(w, "'bidir' and 'reverse_mode' parameters cannot be used together", http.StatusBadRequest)
+		collector.IperfErrors.Inc()
+
+		return
+	}
+
 	protocol := "tcp" 
 
 	protocolParam := r.URL.Query().Get("protocol")
 	if protocolParam != "" {
 
-		if protocolParam != "tcp" && protocolParam != "udp" {
-			http.Error(w, "'protocol' parameter must be 'tcp' or 'udp' (string)", http.StatusBadRequest)
+		if !iperf.ValidateProtocol(protocolParam) {
+			http.Error(w, "'protocol' parameter must be 'tcp', 'udp' or 'sctp' (string)", http.StatusBadRequest)
 			collector.IperfErrors.Inc()
 
 			return
@@ -318,6 +470,81 @@ func (s *Server) probeHandler(w http.ResponseWriter, r *http.Request) {
 
 	bind := r.URL.Query().Get("bind")
 
+	runner := s.config.Runner
+
+	runnerParam := r.URL.Query().Get("runner")
+	if runnerParam != "" {
+		if runnerParam != iperf.RunnerExec && runnerParam != iperf.RunnerNative && runnerParam != iperf.RunnerReplay {
+			http.Error(w, "'runner' parameter must be 'exec', 'native' or 'replay' (string)", http.StatusBadRequest)
+			collector.IperfErrors.Inc()
+
+			return
+		}
+		runner = runnerParam
+	}
+
+	if runner == iperf.RunnerReplay && s.config.Replay == "" {
+		http.Error(w, "'runner' parameter cannot be 'replay': no recordings are configured", http.StatusBadRequest)
+		collector.IperfErrors.Inc()
+
+		return
+	}
+
+	var getServerOutput bool
+
+	getServerOutputParam := r.URL.Query().Get("get_server_output")
+	if getServerOutputParam != "" {
+		var err error
+
+		getServerOutput, err = strconv.ParseBool(getServerOutputParam)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("'get_server_output' parameter must be true or false (boolean): %s", err), http.StatusBadRequest)
+			collector.IperfErrors.Inc()
+
+			return
+		}
+	}
+
+	var binaryVersion string
+	if runner == iperf.RunnerExec {
+		binaryVersion = s.config.Iperf3Versions[s.config.Iperf3Binary].String()
+	}
+
+	// Reject options the iperf3 binary does not support
+	if runner == iperf.RunnerExec && bidir {
+		if err := s.config.Iperf3Versions[s.config.Iperf3Binary].Require(iperf.CapabilityBidir); err != nil {
+			http.Error(w, fmt.Sprintf("'bidir' parameter cannot be used: %s", err), http.StatusBadRequest)
+			collector.IperfErrors.Inc()
+
+			return
+		}
+	}
+
+	// Reject options the native runner does not support
+	if runner == iperf.RunnerNative {
+		if err := config.CheckNativeTarget(collector.TargetConfig{Protocol: protocol, Bidir: bidir}); err != nil {
+			http.Error(w, fmt.Sprintf("'runner' parameter cannot be 'native': %s", err), http.StatusBadRequest)
+			collector.IperfErrors.Inc()
+
+			return
+		}
+	}
+
+	var parallel int
+
+	parallelParam := r.URL.Query().Get("parallel")
+	if parallelParam != "" {
+		var err error
+
+		parallel, err = strconv.Atoi(parallelParam)
+		if err != nil || !iperf.ValidateParallel(parallel) {
+			http.Error(w, fmt.Sprintf("'parallel' parameter must be an integer between 0 and %d", iperf.MaxParallel), http.StatusBadRequest)
+			collector.IperfErrors.Inc()
+
+			return
+		}
+	}
+
 	// Determine the effective timeout for the iperf3 test.
 	// The timeout logic follows these rules:
 	// 1. Start with the Prometheus scrape timeout from the X-Prometheus-Scrape-Timeout-Seconds header
@@ -365,24 +592,66 @@ func (s *Server) probeHandler(w http.ResponseWriter, r *http.Request) {
 
 	runTimeout := time.Duration(timeoutSeconds * float64(time.Second))
 
+	// Wait for the other tests of the destination and a free test slot, the wait counts against the timeout
+	waitStart := time.Now()
+	waitCtx, cancelWait := context.WithTimeout(r.Context(), runTimeout)
+	release, err := s.scheduler.Acquire(waitCtx, scheduler.Destination(target, targetPort), scheduler.SourceProbe)
+	cancelWait()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Timed out waiting for other tests of %s to finish", scheduler.Destination(target, targetPort)), http.StatusServiceUnavailable)
+		collector.IperfErrors.Inc()
+
+		return
+	}
+	defer release()
+
+	runTimeout -= time.Since(waitStart)
+
+	// Shorten the test to the time left
+	if runPeriod >= runTimeout {
+		runPeriod = (runTimeout * 9 / 10).Truncate(time.Second)
+		if runPeriod < time.Second {
+			http.Error(w, "Not enough time left for the test after waiting for other tests", http.StatusServiceUnavailable)
+			collector.IperfErrors.Inc()
+
+			return
+		}
+	}
+
 	start := time.Now()
 	registry := prometheus.NewRegistry()
 
 	// Create collector with probe configuration
 	targetConfig := collector.TargetConfig{
-		Target:      target,
-		Port:        targetPort,
-		Period:      runPeriod,
-		Timeout:     runTimeout,
-		ReverseMode: reverseMode,
-		Protocol:    protocol,
-		Bitrate:     bitrate,
-		Bind:        bind,
+		Target:            target,
+		Port:              targetPort,
+		Period:            runPeriod,
+		Timeout:           runTimeout,
+		ReverseMode:       reverseMode,
+		Bidir:             bidir,
+		Protocol:          protocol,
+		Bitrate:           bitrate,
+		Bind:              bind,
+		Parallel:          parallel,
+		Runner:            runner,
+		GetServerOutput:   getServerOutput,
+		CPUBoundThreshold: &s.config.CPUBoundThreshold,
+		Binary:            s.config.Iperf3Binary,
+		BinaryVersion:     binaryVersion,
 	}
 
-	c := collector.NewCollector(targetConfig, s.logger)
+	var c *collector.Collector
+	if runner == iperf.RunnerReplay {
+		c = collector.NewCollectorWithRunner(targetConfig, s.logger, s.replay)
+	} else {
+		c = collector.NewCollector(targetConfig, s.logger)
+	}
 	registry.MustRegister(c)
 
+	if s.archive != nil {
+		c.ArchiveTo(s.archive)
+	}
+
 	// Delegate http serving to Prometheus client library, which will call collector.Collect.
 	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
 	h.ServeHTTP(w, r)
@@ -414,14 +683,80 @@ func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// runsHandler handles requests to the /runs endpoint, listing the archived runs
+// of the target given by the 'target' parameter, or of all targets.
+func (s *Server) runsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.archive == nil {
+		http.Error(w, "result archive is not enabled", http.StatusNotFound)
+		return
+	}
+
+	runs := s.archive.List(r.URL.Query().Get("name"), r.URL.Query().Get("target"))
+	if runs == nil {
+		runs = []archive.Summary{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		s.logger.Warn("Failed to write archived runs", "err", err)
+	}
+}
+
+// runHandler handles requests to the /runs/{id} endpoint, downloading an archived run.
+func (s *Server) runHandler(w http.ResponseWriter, r *http.Request) {
+	if s.archive == nil {
+		http.Error(w, "result archive is not enabled", http.StatusNotFound)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	f, err := s.archive.Open(id)
+	if errors.Is(err, archive.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("run %q not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to open archived run", "run_id", id, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		s.logger.Error("Failed to open archived run", "run_id", id, "err", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".json"))
+	http.ServeContent(w, r, id+".json", info.ModTime(), f)
+}
+
 // healthHandler handles requests to the /health endpoint.
 func (s *Server) healthHandler(w http.ResponseWriter, _ *http.Request) {
-	// Check if iperf3 exists
-	if err := iperf.CheckIperf3Exists(); err != nil {
-		s.logger.Error("iperf3 command not found", "err", err)
-		http.Error(w, "iperf3 command not found", http.StatusServiceUnavailable)
+	// Check that every iperf3 binary in use exists
+	if binaries := s.config.Iperf3Binaries(); len(binaries) > 0 {
+		if err := iperf.CheckIperf3Exists(binaries...); err != nil {
+			s.logger.Error("iperf3 command not found", "err", err)
+			http.Error(w, "iperf3 command not found", http.StatusServiceUnavailable)
 
-		return
+			return
+		}
+	}
+
+	if binaries := s.config.Iperf2Binaries(); len(binaries) > 0 {
+		if err := iperf.CheckIperf2Exists(binaries...); err != nil {
+			s.logger.Error("iperf2 command not found", "err", err)
+			http.Error(w, "iperf2 command not found", http.StatusServiceUnavailable)
+
+			return
+		}
 	}
 
 	w.WriteHeader(http.StatusOK)
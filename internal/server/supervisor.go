@@ -0,0 +1,295 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/config"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// serverMinBackoff is the delay before the first restart of a crashed iperf3 server.
+	serverMinBackoff = time.Second
+	// serverMaxBackoff caps the exponential restart backoff.
+	serverMaxBackoff = time.Minute
+	// serverStableAfter is how long a server must run before its restart backoff is reset.
+	serverStableAfter = time.Minute
+	// serverHealthCheckTimeout is the timeout for connecting to a managed server.
+	serverHealthCheckTimeout = 5 * time.Second
+	// serverHealthCheckFailures is the number of consecutive failed health checks
+	// after which a managed server is killed and restarted.
+	serverHealthCheckFailures = 3
+	// serverAcceptedMessage is printed by iperf3 -s for every test it serves.
+	serverAcceptedMessage = "Accepted connection from"
+	// serverHealthCheckMessage is printed by iperf3 -s for connections closed before sending
+	// the test cookie, which every health check does.
+	serverHealthCheckMessage = "unable to receive cookie"
+	// serverLogPollInterval is how often the JSON log of a managed server is read.
+	serverLogPollInterval = time.Second
+)
+
+// Metrics about the iperf3 servers managed by the exporter.
+var (
+	serverUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("iperf3", "server", "up"),
+			Help: "Is the managed iperf3 server running and accepting connections (1 for yes, 0 for no).",
+		},
+		[]string{"port", "bind"},
+	)
+	serverRestarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: prometheus.BuildFQName("iperf3", "server", "restarts_total"),
+			Help: "Number of times the managed iperf3 server was restarted.",
+		},
+		[]string{"port", "bind"},
+	)
+	serverTestsServed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: prometheus.BuildFQName("iperf3", "server", "tests_served_total"),
+			Help: "Number of tests served by the managed iperf3 server.",
+		},
+		[]string{"port", "bind"},
+	)
+)
+
+// supervisor launches the configured iperf3 servers and keeps them running.
+type supervisor struct {
+	servers    []config.ServerConfig
+	binary     string
+	serverLogs *collector.ServerLogCollector
+	logger     *slog.Logger
+}
+
+// newSupervisor creates a supervisor running the given servers from binary. The results
+// of servers with a log file are recorded in serverLogs.
+func newSupervisor(servers []config.ServerConfig, binary string, serverLogs *collector.ServerLogCollector, logger *slog.Logger) *supervisor {
+	return &supervisor{
+		servers:    servers,
+		binary:     binary,
+		serverLogs: serverLogs,
+		logger:     logger,
+	}
+}
+
+// run starts supervising every configured server until ctx is done.
+func (sv *supervisor) run(ctx context.Context, wg *sync.WaitGroup) {
+	if len(sv.servers) == 0 {
+		return
+	}
+
+	sv.logger.Info("Starting iperf3 servers", "server_count", len(sv.servers))
+
+	for _, srv := range sv.servers {
+		wg.Add(1)
+
+		go func(srv config.ServerConfig) {
+			defer wg.Done()
+			sv.supervise(ctx, srv)
+		}(srv)
+	}
+}
+
+// supervise runs a single server, restarting it with exponential backoff whenever it exits.
+func (sv *supervisor) supervise(ctx context.Context, srv config.ServerConfig) {
+	labels := prometheus.Labels{"port": strconv.Itoa(srv.Port), "bind": srv.Bind}
+	logger := sv.logger.With("port", srv.Port, "bind", srv.Bind)
+
+	// Export the series before the first start so that a server that never comes up is visible
+	serverUp.With(labels).Set(0)
+	serverRestarts.With(labels).Add(0)
+	serverTestsServed.With(labels).Add(0)
+
+	if srv.LogFile != "" {
+		go sv.followLog(ctx, srv, labels, logger)
+	}
+
+	backoff := serverMinBackoff
+
+	for {
+		started := time.Now()
+		err := sv.runOnce(ctx, srv, labels, logger)
+		serverUp.With(labels).Set(0)
+
+		if ctx.Err() != nil {
+			logger.Info("iperf3 server stopped")
+			return
+		}
+
+		if time.Since(started) > serverStableAfter {
+			backoff = serverMinBackoff
+		}
+
+		logger.Warn("iperf3 server exited, restarting", "err", err, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, serverMaxBackoff)
+		serverRestarts.With(labels).Inc()
+	}
+}
+
+// runOnce starts the server process and blocks until it exits.
+func (sv *supervisor) runOnce(ctx context.Context, srv config.ServerConfig, labels prometheus.Labels, logger *slog.Logger) error {
+	procCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := iperf.NewServerCmd(procCtx, sv.binary, srv.Port, srv.Bind, srv.LogFile)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	logger.Info("iperf3 server started", "pid", cmd.Process.Pid)
+
+	go sv.healthCheck(procCtx, srv, labels, logger, cancel)
+
+	// Both pipes must be drained before Wait closes them
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		scanLines(stderr, func(line string) {
+			if strings.Contains(line, serverHealthCheckMessage) {
+				logger.Debug("iperf3 server error output", "line", line)
+				return
+			}
+
+			logger.Warn("iperf3 server error output", "line", line)
+		})
+	}()
+
+	scanLines(stdout, func(line string) {
+		if strings.Contains(line, serverAcceptedMessage) {
+			serverTestsServed.With(labels).Inc()
+		}
+
+		logger.Debug("iperf3 server output", "line", line)
+	})
+
+	wg.Wait()
+
+	return cmd.Wait()
+}
+
+// followLog ingests the JSON log of a server started with a log file. Tests served
+// before the exporter started are ingested but not counted as served.
+func (sv *supervisor) followLog(ctx context.Context, srv config.ServerConfig, labels prometheus.Labels, logger *slog.Logger) {
+	started := time.Now().Truncate(time.Second)
+	tailer := iperf.NewServerLogTailer(srv.LogFile, serverLogPollInterval, logger)
+
+	tailer.Run(ctx, func(result iperf.ServerResult) {
+		if !result.Timestamp.Before(started) {
+			serverTestsServed.With(labels).Inc()
+		}
+
+		sv.serverLogs.Record(result)
+	})
+}
+
+// healthCheck periodically connects to the server, updating its up metric,
+// and calls kill after too many consecutive failures.
+func (sv *supervisor) healthCheck(ctx context.Context, srv config.ServerConfig, labels prometheus.Labels, logger *slog.Logger, kill context.CancelFunc) {
+	address := net.JoinHostPort(healthCheckHost(srv.Bind), strconv.Itoa(srv.Port))
+
+	// Give the server a moment to bind before the first check
+	timer := time.NewTimer(serverMinBackoff)
+	defer timer.Stop()
+
+	failures := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		conn, err := net.DialTimeout("tcp", address, serverHealthCheckTimeout)
+		if err == nil {
+			_ = conn.Close()
+			failures = 0
+
+			serverUp.With(labels).Set(1)
+		} else {
+			failures++
+
+			serverUp.With(labels).Set(0)
+			logger.Warn("iperf3 server health check failed", "err", err, "failures", failures)
+
+			if failures >= serverHealthCheckFailures {
+				logger.Error("iperf3 server is unhealthy, killing it")
+				kill()
+
+				return
+			}
+		}
+
+		timer.Reset(srv.HealthCheckInterval)
+	}
+}
+
+// healthCheckHost returns the address to connect to for a server bound to bind.
+func healthCheckHost(bind string) string {
+	ip := net.ParseIP(bind)
+
+	switch {
+	case ip == nil:
+		return "127.0.0.1"
+	case ip.IsUnspecified() && ip.To4() == nil:
+		return "::1"
+	case ip.IsUnspecified():
+		return "127.0.0.1"
+	default:
+		return bind
+	}
+}
+
+// scanLines calls fn for every line read from r until it is closed.
+func scanLines(r io.Reader, fn func(string)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// TestHealthCheckHost verifies the address health checks connect to for every kind of bind address.
+func TestHealthCheckHost(t *testing.T) {
+	tests := []struct {
+		bind string
+		want string
+	}{
+		{"", "127.0.0.1"},
+		{"not-an-ip", "127.0.0.1"},
+		{"0.0.0.0", "127.0.0.1"},
+		{"::", "::1"},
+		{"192.168.1.10", "192.168.1.10"},
+		{"fe80::1", "fe80::1"},
+	}
+
+	for _, tt := range tests {
+		if got := healthCheckHost(tt.bind); got != tt.want {
+			t.Errorf("healthCheckHost(%q) = %q, want %q", tt.bind, got, tt.want)
+		}
+	}
+}
+
+// TestSupervisorRestartsExitedServer verifies that a server that exits is marked down and restarted.
+func TestSupervisorRestartsExitedServer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-ins for iperf3 are not supported on Windows")
+	}
+
+	// The stand-in runs for a while and exits, the health checks connect to a listener of the test
+	binary := filepath.Join(t.TempDir(), "iperf3")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\nsleep 2\nexit 1\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write fake iperf3: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	labels := prometheus.Labels{"port": strconv.Itoa(port), "bind": ""}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+
+	sv := newSupervisor([]config.ServerConfig{{Port: port, HealthCheckInterval: 100 * time.Millisecond}}, binary, nil, slog.Default())
+	sv.run(ctx, &wg)
+
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	waitFor(t, "the server to be up", func() bool {
+		return metricValue(t, serverUp.With(labels)) == 1
+	})
+
+	// Stop answering health checks so that the restarted server stays down
+	_ = listener.Close()
+
+	waitFor(t, "the server to be restarted", func() bool {
+		return metricValue(t, serverRestarts.With(labels)) >= 1
+	})
+
+	if up := metricValue(t, serverUp.With(labels)); up != 0 {
+		t.Errorf("Expected the restarted server to be down, got %v", up)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after 10 seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// metricValue returns the value of a gauge or counter.
+func metricValue(t *testing.T, metric prometheus.Metric) float64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := metric.Write(&m); err != nil {
+		t.Fatalf("Failed to read metric: %v", err)
+	}
+
+	if m.GetGauge() != nil {
+		return m.GetGauge().GetValue()
+	}
+
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/archive"
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// TestArchiveRetention verifies that the archive lists runs newest first
+// and removes the oldest runs once it is over its size limit.
+func TestArchiveRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := archive.New(dir, time.Hour, 0, slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+
+	var ids []string
+	for _, name := range []string{"a-upload", "b-upload", "a-download"} {
+		id, err := a.Store(archive.Run{Name: name, Target: name[:1] + ".example.com", Output: json.RawMessage(`{"end":{}}`)})
+		if err != nil {
+			t.Fatalf("Failed to store run: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	runs := a.List("", "a.example.com")
+	if len(runs) != 2 || runs[0].ID != ids[2] || runs[1].ID != ids[0] {
+		t.Fatalf("Expected the runs of a.example.com newest first, got %+v", runs)
+	}
+
+	if named := a.List("a-upload", ""); len(named) != 1 || named[0].ID != ids[0] {
+		t.Fatalf("Expected the single run of a-upload, got %+v", named)
+	}
+
+	// Reopening with a size limit of a single run keeps only the newest one
+	a, err = archive.New(dir, time.Hour, runs[0].Size, slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to reopen archive: %v", err)
+	}
+
+	if runs := a.List("", ""); len(runs) != 1 || runs[0].ID != ids[2] {
+		t.Fatalf("Expected only the newest run to be kept, got %+v", runs)
+	}
+
+	if _, err := a.Open(ids[0]); err != archive.ErrNotFound {
+		t.Errorf("Expected a removed run to be not found, got %v", err)
+	}
+
+	if _, err := a.Open("../config"); err != archive.ErrNotFound {
+		t.Errorf("Expected an invalid run ID to be not found, got %v", err)
+	}
+}
+
+// TestCollectorArchivesRuns verifies that the raw output of a run is archived
+// and that its run ID is exported with the metrics.
+func TestCollectorArchivesRuns(t *testing.T) {
+	binary := writeFakeIperf3(t, `#!/bin/sh
+echo 'warning: ignoring unknown option' >&2
+echo '{"start":{},"intervals":[],"end":{"sum_sent":{"seconds":1,"bytes":125,"bits_per_second":1000}}}'
+`)
+
+	a, err := archive.New(t.TempDir(), 0, 0, slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+
+	c := collector.NewCollector(collector.TargetConfig{
+		Target:   "127.0.0.1",
+		Port:     5201,
+		Period:   time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "tcp",
+		Runner:   iperf.RunnerExec,
+		Binary:   binary,
+	}, slog.Default())
+	c.ArchiveTo(a)
+
+	body := scrape(t, c)
+
+	runs := a.List("", "127.0.0.1")
+	if len(runs) != 1 || !runs[0].Success {
+		t.Fatalf("Expected a single successful archived run, got %+v", runs)
+	}
+
+	expectMetrics(t, body, `iperf3_last_run_info\{.*run_id="`+regexp.QuoteMeta(runs[0].ID)+`".*\} 1`)
+
+	f, err := a.Open(runs[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to open archived run: %v", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Failed to read archived run: %v", err)
+	}
+
+	var run archive.Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		t.Fatalf("Failed to parse archived run: %v", err)
+	}
+
+	if run.Command[0] != binary || run.ExitCode != 0 || run.Stderr != "warning: ignoring unknown option\n" || len(run.Output) == 0 {
+		t.Errorf("Unexpected archived run: %+v", run)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// findFamily returns the metric family with the given name, or nil.
+func findFamily(families []*dto.MetricFamily, name string) *dto.MetricFamily {
+	for _, family := range families {
+		if family.GetName() == name {
+			return family
+		}
+	}
+
+	return nil
+}
+
+// TestMetricsCache verifies that cached results are exported with the time their test
+// finished and their age, and that they are dropped once they went stale.
+func TestMetricsCache(t *testing.T) {
+	cfg := collector.TargetConfig{
+		Name:     "cached",
+		Target:   "10.0.0.1",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "tcp",
+		Interval: time.Minute,
+	}
+
+	c := collector.NewCollectorWithRunner(cfg, slog.Default(), &MockRunner{Result: iperf.Result{
+		Success:               true,
+		Protocol:              "tcp",
+		ReceivedBitsPerSecond: 1e9,
+	}})
+
+	cache := collector.NewMetricsCache(3)
+
+	run := c.Run()
+	run.Finished = time.Now().Add(-2 * time.Minute)
+	cache.Update(cfg.Name, c, run, cfg.Interval)
+
+	families, err := cache.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather the cache: %v", err)
+	}
+
+	received := findFamily(families, "iperf3_received_bits_per_second")
+	if received == nil || len(received.GetMetric()) != 1 {
+		t.Fatalf("Expected one iperf3_received_bits_per_second sample, got %v", received)
+	}
+
+	if got := received.GetMetric()[0].GetTimestampMs(); got != run.Finished.UnixMilli() {
+		t.Errorf("Expected the sample timestamp %d, got %d", run.Finished.UnixMilli(), got)
+	}
+
+	age := findFamily(families, "iperf3_result_age_seconds")
+	if age == nil || len(age.GetMetric()) != 1 {
+		t.Fatalf("Expected one iperf3_result_age_seconds sample, got %v", age)
+	}
+
+	if got := age.GetMetric()[0].GetGauge().GetValue(); got < 120 || got > 130 {
+		t.Errorf("Expected a result age of about 120s, got %v", got)
+	}
+
+	if age.GetMetric()[0].TimestampMs != nil {
+		t.Error("Expected the result age to be exported without a timestamp")
+	}
+
+	// Older than 3 intervals of the target
+	run.Finished = time.Now().Add(-4 * time.Minute)
+	cache.Update(cfg.Name, c, run, cfg.Interval)
+
+	families, err = cache.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather the cache: %v", err)
+	}
+
+	if family := findFamily(families, "iperf3_up"); family != nil {
+		t.Errorf("Expected the stale result to be dropped, got %v", family)
+	}
+}
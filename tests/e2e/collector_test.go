@@ -0,0 +1,349 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrapeCollector registers a collector backed by runner and returns the exposition output.
+func scrapeCollector(t *testing.T, cfg collector.TargetConfig, runner iperf.Runner) string {
+	t.Helper()
+
+	return scrape(t, collector.NewCollectorWithRunner(cfg, slog.Default(), runner))
+}
+
+// scrape registers c in a new registry and returns the exposition output.
+func scrape(t *testing.T, c prometheus.Collector) string {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	ts := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+
+	return string(body)
+}
+
+// expectMetrics fails the test for every pattern that is not found in body.
+func expectMetrics(t *testing.T, body string, patterns ...string) {
+	t.Helper()
+
+	for _, p := range patterns {
+		if !regexp.MustCompile(p).MatchString(body) {
+			t.Errorf("Expected metric matching pattern %q not found in response", p)
+		}
+	}
+}
+
+// TestIntervalMetrics verifies the per-interval bitrate distribution.
+func TestIntervalMetrics(t *testing.T) {
+	runner := &MockRunner{
+		Result: iperf.Result{
+			Success:  true,
+			Protocol: "tcp",
+			Intervals: []iperf.Interval{
+				{Bytes: 1, BitsPerSecond: 1000, Omitted: true},
+				{Bytes: 100, BitsPerSecond: 100},
+				{Bytes: 0, BitsPerSecond: 0},
+				{Bytes: 300, BitsPerSecond: 300},
+				{Bytes: 200, BitsPerSecond: 200},
+				{Bytes: 400, BitsPerSecond: 400},
+			},
+		},
+	}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Target:   "test.example.com",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  30 * time.Second,
+		Protocol: "tcp",
+	}, runner)
+
+	expectMetrics(t, body,
+		`iperf3_interval_min_bits_per_second\{.*target="test.example.com"\} 0`,
+		`iperf3_interval_max_bits_per_second\{.*target="test.example.com"\} 400`,
+		`iperf3_interval_stddev_bits_per_second\{.*target="test.example.com"\} 141\.42`,
+		`iperf3_interval_bits_per_second\{.*quantile="0.5".*\} 200`,
+		`iperf3_interval_bits_per_second\{.*quantile="0.05".*\} 20`,
+		`iperf3_interval_bits_per_second\{.*quantile="0.95".*\} 380`,
+		`iperf3_zero_byte_intervals\{.*target="test.example.com"\} 1`,
+	)
+}
+
+// TestParallelStreamMetrics verifies the per-stream metrics and the fairness index.
+func TestParallelStreamMetrics(t *testing.T) {
+	runner := &MockRunner{
+		Result: iperf.Result{
+			Success:  true,
+			Protocol: "tcp",
+			Streams: []iperf.StreamResult{
+				{SentBytes: 100, SentBitsPerSecond: 800, ReceivedBytes: 100, ReceivedBitsPerSecond: 800, Retransmits: 1},
+				{SentBytes: 0, SentBitsPerSecond: 0, ReceivedBytes: 0, ReceivedBitsPerSecond: 0, Retransmits: 7},
+			},
+		},
+	}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Target:   "test.example.com",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  30 * time.Second,
+		Protocol: "tcp",
+		Parallel: 2,
+	}, runner)
+
+	expectMetrics(t, body,
+		`iperf3_stream_received_bits_per_second\{.*stream="0".*\} 800`,
+		`iperf3_stream_received_bits_per_second\{.*stream="1".*\} 0`,
+		`iperf3_stream_retransmits\{.*stream="1".*\} 7`,
+		`iperf3_fairness_index\{.*target="test.example.com"\} 0.5`,
+	)
+}
+
+// TestTCPInfoMetrics verifies that TCP_INFO metrics are exported only when reported.
+func TestTCPInfoMetrics(t *testing.T) {
+	cfg := collector.TargetConfig{
+		Target:   "test.example.com",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  30 * time.Second,
+		Protocol: "tcp",
+	}
+
+	body := scrapeCollector(t, cfg, &MockRunner{
+		Result: iperf.Result{
+			Success:  true,
+			Protocol: "tcp",
+			TCPInfo: &iperf.TCPInfo{
+				MaxSndCwnd: 1048576,
+				MinRTT:     2 * time.Millisecond,
+				MaxRTT:     40 * time.Millisecond,
+				MeanRTT:    10 * time.Millisecond,
+			},
+		},
+	})
+
+	expectMetrics(t, body,
+		`iperf3_tcp_min_rtt_seconds\{.*\} 0.002`,
+		`iperf3_tcp_max_rtt_seconds\{.*\} 0.04`,
+		`iperf3_tcp_mean_rtt_seconds\{.*\} 0.01`,
+		`iperf3_tcp_max_snd_cwnd_bytes\{.*\} 1.048576e\+06`,
+	)
+
+	body = scrapeCollector(t, cfg, &MockRunner{
+		Result: iperf.Result{Success: true, Protocol: "tcp"},
+	})
+
+	if regexp.MustCompile(`iperf3_tcp_mean_rtt_seconds\{`).MatchString(body) {
+		t.Errorf("Expected no TCP_INFO metrics without TCP_INFO, got:\n%s", body)
+	}
+}
+
+// TestProbeBinaryInfo verifies that the iperf3 binary and version in use are exported with the results,
+// and that the version labels the results.
+func TestProbeBinaryInfo(t *testing.T) {
+	runner := &MockRunner{Result: iperf.Result{Success: true, Protocol: "tcp"}}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Target:        "test.example.com",
+		Port:          5201,
+		Period:        5 * time.Second,
+		Timeout:       10 * time.Second,
+		Protocol:      "tcp",
+		Runner:        iperf.RunnerExec,
+		Binary:        "/opt/iperf3/bin/iperf3",
+		BinaryVersion: "3.17.1",
+	}, runner)
+
+	expectMetrics(t, body,
+		`iperf3_probe_binary_info\{binary="/opt/iperf3/bin/iperf3",.*target="test.example.com",version="3.17.1"\} 1`,
+		`iperf3_up\{iperf3_version="3.17.1",.*target="test.example.com"\} 1`,
+		`iperf3_sent_bits_per_second\{iperf3_version="3.17.1",.*\} 0`,
+	)
+}
+
+// TestCPUUtilizationMetrics verifies the CPU utilization gauges and the CPU bound flag.
+func TestCPUUtilizationMetrics(t *testing.T) {
+	threshold := 90.0
+
+	cfg := collector.TargetConfig{
+		Target:            "test.example.com",
+		Port:              5201,
+		Period:            5 * time.Second,
+		Timeout:           10 * time.Second,
+		Protocol:          "tcp",
+		CPUBoundThreshold: &threshold,
+	}
+
+	body := scrapeCollector(t, cfg, &MockRunner{Result: iperf.Result{
+		Success:  true,
+		Protocol: "tcp",
+		CPUUtilization: &iperf.CPUUtilization{
+			HostTotal:    97.5,
+			HostUser:     10.5,
+			HostSystem:   87,
+			RemoteTotal:  20,
+			RemoteUser:   5,
+			RemoteSystem: 15,
+		},
+	}})
+
+	expectMetrics(t, body,
+		`iperf3_cpu_utilization_percent\{mode="system",.*side="host",.*\} 87`,
+		`iperf3_cpu_utilization_percent\{mode="total",.*side="remote",.*\} 20`,
+		`iperf3_result_cpu_bound\{.*target="test.example.com"\} 1`,
+	)
+
+	body = scrapeCollector(t, cfg, &MockRunner{Result: iperf.Result{
+		Success:        true,
+		Protocol:       "tcp",
+		CPUUtilization: &iperf.CPUUtilization{HostTotal: 40, RemoteTotal: 20},
+	}})
+
+	expectMetrics(t, body, `iperf3_result_cpu_bound\{.*target="test.example.com"\} 0`)
+
+	// A threshold of 0 disables the flag
+	threshold = 0
+
+	body = scrapeCollector(t, cfg, &MockRunner{Result: iperf.Result{
+		Success:        true,
+		Protocol:       "tcp",
+		CPUUtilization: &iperf.CPUUtilization{HostTotal: 97.5, RemoteTotal: 20},
+	}})
+
+	if strings.Contains(body, "iperf3_result_cpu_bound{") {
+		t.Error("Expected no CPU bound flag with a threshold of 0")
+	}
+}
+
+// TestStartInfoMetrics verifies that the connections, the effective parameters and the
+// start time reported by iperf3 are exported as info metrics.
+func TestStartInfoMetrics(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "run.json")
+	if err := os.WriteFile(recording, []byte(`{
+  "start": {
+    "connected": [
+      {"socket": 5, "local_host": "10.0.0.2", "local_port": 40312, "remote_host": "10.0.0.1", "remote_port": 5201},
+      {"socket": 7, "local_host": "10.0.0.2", "local_port": 40314, "remote_host": "10.0.0.1", "remote_port": 5201}
+    ],
+    "version": "iperf 3.16",
+    "system_info": "Linux probe 6.8.0 x86_64",
+    "timestamp": {"time": "Sat, 17 Oct 2026 10:00:00 GMT", "timesecs": 1792231200},
+    "cookie": "abcdefghijklmnopqrstuvwxyz234567abcd",
+    "test_start": {"protocol": "TCP", "num_streams": 2, "blksize": 131072, "omit": 0, "duration": 10, "reverse": 0, "tos": 32}
+  },
+  "intervals": [],
+  "end": {
+    "sum_sent": {"seconds": 10, "bytes": 1250000, "bits_per_second": 1000000},
+    "sum_received": {"seconds": 10, "bytes": 1250000, "bits_per_second": 1000000}
+  }
+}`), 0o600); err != nil {
+		t.Fatalf("Failed to write recording: %v", err)
+	}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Target:   "10.0.0.1",
+		Port:     5201,
+		Period:   10 * time.Second,
+		Timeout:  15 * time.Second,
+		Protocol: "tcp",
+	}, iperf.NewReplayRunner(recording, slog.Default()))
+
+	expectMetrics(t, body,
+		`iperf3_connection_info\{local_host="10.0.0.2",local_port="40312",port="5201",protocol="tcp",remote_host="10.0.0.1",remote_port="5201",reverse="false",target="10.0.0.1"\} 1`,
+		`iperf3_connection_info\{local_host="10.0.0.2",local_port="40314",.*\} 1`,
+		`iperf3_test_info\{blksize="131072",cookie="abcdefghijklmnopqrstuvwxyz234567abcd",duration="10",num_streams="2",omit="0",port="5201",protocol="tcp",reverse="false",system_info="Linux probe 6.8.0 x86_64",target="10.0.0.1",test_reverse="false",tos="32",version="iperf 3.16"\} 1`,
+		`iperf3_last_run_timestamp_seconds\{.*target="10.0.0.1"\} 1.7922312e\+09`,
+	)
+}
+
+// TestBitrateMetrics verifies the bitrate gauges and the requested bitrate, which
+// defaults to the iperf3 UDP bitrate and is omitted for unlimited tests.
+func TestBitrateMetrics(t *testing.T) {
+	result := iperf.Result{
+		Success:               true,
+		Protocol:              "udp",
+		SentBitsPerSecond:     1000000,
+		ReceivedBitsPerSecond: 987654,
+	}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Target:   "test.example.com",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "udp",
+	}, &MockRunner{Result: result})
+
+	expectMetrics(t, body,
+		`iperf3_sent_bits_per_second\{.*target="test.example.com"\} 1e\+06`,
+		`iperf3_received_bits_per_second\{.*target="test.example.com"\} 987654`,
+		`iperf3_requested_bitrate_bits_per_second\{.*protocol="udp",.*\} 1e\+06`,
+	)
+
+	body = scrapeCollector(t, collector.TargetConfig{
+		Target:   "test.example.com",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "tcp",
+		Bitrate:  "2.5G",
+	}, &MockRunner{Result: iperf.Result{Success: true, Protocol: "tcp"}})
+
+	expectMetrics(t, body, `iperf3_requested_bitrate_bits_per_second\{.*protocol="tcp",.*\} 2.5e\+09`)
+
+	body = scrapeCollector(t, collector.TargetConfig{
+		Target:   "test.example.com",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "tcp",
+	}, &MockRunner{Result: iperf.Result{Success: true, Protocol: "tcp"}})
+
+	if strings.Contains(body, "iperf3_requested_bitrate_bits_per_second{") {
+		t.Error("Expected no requested bitrate for an unlimited TCP test")
+	}
+}
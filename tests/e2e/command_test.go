@@ -0,0 +1,110 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// fakeCommand records its arguments and prints a TCP result in the iperf3 JSON format.
+const fakeCommand = `#!/bin/sh
+echo "$@" > "$(dirname "$0")/args"
+cat <<'JSON'
+{
+  "intervals": [{"sum": {"start": 0, "end": 1, "seconds": 1, "bytes": 1250000, "bits_per_second": 10000000}}],
+  "end": {
+    "sum_sent": {"seconds": 1, "bytes": 1250000, "bits_per_second": 10000000, "retransmits": 1},
+    "sum_received": {"seconds": 1, "bytes": 1187500, "bits_per_second": 9500000}
+  }
+}
+JSON
+`
+
+// TestCommandRunner verifies that the command runner expands the placeholders of
+// the command template and parses its iperf3 compatible output.
+func TestCommandRunner(t *testing.T) {
+	command := writeFakeIperf3(t, fakeCommand)
+
+	runner := iperf.NewCommandRunner([]string{
+		command, "--host={target}", "{port}", "{period}", "{bitrate}", "{protocol}", "{parallel}", "{reverse}",
+	}, slog.Default())
+
+	result := runner.Run(context.Background(), iperf.Config{
+		Target:      "wan.example.com",
+		Port:        9000,
+		Period:      10 * time.Second,
+		Bitrate:     "100M",
+		Protocol:    "tcp",
+		ReverseMode: true,
+		Logger:      slog.Default(),
+	})
+
+	if !result.Success {
+		t.Fatalf("Expected a successful result, got error: %v", result.Error)
+	}
+
+	args, err := os.ReadFile(filepath.Join(filepath.Dir(command), "args"))
+	if err != nil {
+		t.Fatalf("Failed to read the recorded arguments: %v", err)
+	}
+
+	if got, want := strings.TrimSpace(string(args)), "--host=wan.example.com 9000 10 100M tcp 1 true"; got != want {
+		t.Errorf("Expected arguments %q, got %q", want, got)
+	}
+
+	if result.SentBitsPerSecond != 10000000 || result.ReceivedBitsPerSecond != 9500000 {
+		t.Errorf("Unexpected bitrates: sent %v, received %v", result.SentBitsPerSecond, result.ReceivedBitsPerSecond)
+	}
+
+	if result.Retransmits != 1 {
+		t.Errorf("Expected 1 retransmit, got %v", result.Retransmits)
+	}
+
+	if result.Output == nil || result.Output.ExitCode != 0 {
+		t.Errorf("Expected the raw output with exit code 0, got %+v", result.Output)
+	}
+}
+
+// TestCommandRunnerFailure verifies that a failing command fails the test with its stderr.
+func TestCommandRunnerFailure(t *testing.T) {
+	command := writeFakeIperf3(t, "#!/bin/sh\necho 'connection refused' >&2\nexit 3\n")
+
+	result := iperf.NewCommandRunner([]string{command, "{target}"}, slog.Default()).Run(context.Background(), iperf.Config{
+		Target:   "wan.example.com",
+		Port:     9000,
+		Period:   time.Second,
+		Protocol: "tcp",
+		Logger:   slog.Default(),
+	})
+
+	if result.Success {
+		t.Fatal("Expected the test to fail")
+	}
+
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "connection refused") {
+		t.Errorf("Expected the error to contain stderr, got %v", result.Error)
+	}
+
+	if result.Output == nil || result.Output.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %+v", result.Output)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// TestIperf2RunnerTCP verifies that the sum report of parallel iperf2 streams is used for the totals.
+func TestIperf2RunnerTCP(t *testing.T) {
+	binary := writeFakeIperf3(t, `#!/bin/sh
+case "$*" in *"-y C"*"-P 2"*) ;; *) echo "unexpected arguments: $*" >&2; exit 1 ;; esac
+echo '20261017120000,10.0.0.1,40000,10.0.0.2,5001,3,0.0-10.0,6250000,5000000'
+echo '20261017120000,10.0.0.1,40002,10.0.0.2,5001,4,0.0-10.0,3750000,3000000'
+echo '20261017120000,10.0.0.1,0,10.0.0.2,5001,-1,0.0-10.0,10000000,8000000'
+`)
+
+	result := iperf.NewIperf2Runner(slog.Default()).Run(context.Background(), iperf.Config{
+		Target:   "10.0.0.2",
+		Port:     5001,
+		Period:   10 * time.Second,
+		Protocol: "tcp",
+		Parallel: 2,
+		Binary:   binary,
+		Logger:   slog.Default(),
+	})
+
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.Error)
+	}
+
+	if result.SentBytes != 10000000 || result.ReceivedBitsPerSecond != 8000000 || result.SentSeconds != 10 {
+		t.Errorf("Unexpected totals: %+v", result)
+	}
+
+	if len(result.Streams) != 2 || result.Streams[1].SentBitsPerSecond != 3000000 {
+		t.Errorf("Unexpected streams: %+v", result.Streams)
+	}
+}
+
+// TestIperf2RunnerUDP verifies that UDP jitter and loss are taken from the server report
+// and exported under the same metric names as for iperf3.
+func TestIperf2RunnerUDP(t *testing.T) {
+	binary := writeFakeIperf3(t, `#!/bin/sh
+echo '20261017120000,10.0.0.1,40000,10.0.0.2,5001,3,0.0-10.0,1310720,1048576'
+echo '20261017120010,10.0.0.2,5001,10.0.0.1,40000,3,0.0-10.0,1297920,1038336,0.125,10,900,1.111,0'
+`)
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Target:   "10.0.0.2",
+		Port:     5001,
+		Period:   10 * time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "udp",
+		Binary:   binary,
+	}, iperf.NewIperf2Runner(slog.Default()))
+
+	expectMetrics(t, body,
+		`iperf3_up\{.*protocol="udp".*\} 1`,
+		`iperf3_sent_bytes\{.*\} 1\.31072e\+06`,
+		`iperf3_received_bytes\{.*\} 1\.29792e\+06`,
+		`iperf3_received_jitter_ms\{.*\} 0\.125`,
+		`iperf3_received_lost_packets\{.*\} 10`,
+		`iperf3_received_packets\{.*\} 900`,
+	)
+}
+
+// TestIperf2RunnerError verifies that an iperf2 error on stderr fails the test even with a zero exit status.
+func TestIperf2RunnerError(t *testing.T) {
+	binary := writeFakeIperf3(t, `#!/bin/sh
+echo 'connect failed: Connection refused' >&2
+`)
+
+	result := iperf.NewIperf2Runner(slog.Default()).Run(context.Background(), iperf.Config{
+		Target:   "10.0.0.2",
+		Port:     5001,
+		Period:   time.Second,
+		Protocol: "tcp",
+		Binary:   binary,
+		Logger:   slog.Default(),
+	})
+
+	if result.Success || result.FailureReason != iperf.FailureConnectionRefused {
+		t.Errorf("Expected a connection_refused failure, got success=%v reason=%q", result.Success, result.FailureReason)
+	}
+}
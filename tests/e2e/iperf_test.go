@@ -0,0 +1,88 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// TestClassifyFailure verifies the mapping of iperf3 error messages to failure reasons.
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		message string
+		ctxErr  error
+		want    iperf.FailureReason
+	}{
+		{"unable to connect to server: Connection refused", nil, iperf.FailureConnectionRefused},
+		{"the server is busy running a test. try again later", nil, iperf.FailureServerBusy},
+		{"unable to resolve host: Name or service not known", nil, iperf.FailureDNS},
+		{"unable to connect to server: Connection timed out", nil, iperf.FailureTimeout},
+		{"test authorization failed", nil, iperf.FailureAuth},
+		{"", context.DeadlineExceeded, iperf.FailureTimeout},
+		{"unable to connect to server: Connection refused", context.DeadlineExceeded, iperf.FailureTimeout},
+		{"something unexpected happened", nil, iperf.FailureUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := iperf.ClassifyFailure(tt.message, tt.ctxErr); got != tt.want {
+			t.Errorf("ClassifyFailure(%q, %v) = %q, want %q", tt.message, tt.ctxErr, got, tt.want)
+		}
+	}
+}
+
+// TestParseVersion verifies the parsing of iperf3 --version output and the capability checks.
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+		bidir  bool
+		stream bool
+	}{
+		{"iperf 3.16 (cJSON 1.7.15)\nLinux host 6.1.0 #1 SMP x86_64\n", "3.16", true, false},
+		{"iperf 3.1.3\n", "3.1.3", false, false},
+		{"iperf 3.7 (cJSON 1.5.2)\n", "3.7", true, false},
+		{"iperf 3.18+ (cJSON 1.7.15)\n", "3.18+", true, true},
+	}
+
+	for _, tt := range tests {
+		v, err := iperf.ParseVersion(tt.output)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) failed: %v", tt.output, err)
+			continue
+		}
+
+		if v.String() != tt.want {
+			t.Errorf("ParseVersion(%q) = %q, want %q", tt.output, v, tt.want)
+		}
+
+		if got := v.Supports(iperf.CapabilityBidir); got != tt.bidir {
+			t.Errorf("Version %s supports --bidir = %v, want %v", v, got, tt.bidir)
+		}
+
+		if got := v.Supports(iperf.CapabilityJSONStream); got != tt.stream {
+			t.Errorf("Version %s supports --json-stream = %v, want %v", v, got, tt.stream)
+		}
+	}
+
+	if _, err := iperf.ParseVersion("command not found"); err == nil {
+		t.Error("Expected an error for unrecognized version output")
+	}
+
+	if !(iperf.Version{}).Supports(iperf.CapabilityJSONStream) {
+		t.Error("Expected an unknown version to support every capability")
+	}
+}
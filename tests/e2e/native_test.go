@@ -0,0 +1,346 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// standInServer is a minimal in-process iperf3 server for TCP tests.
+type standInServer struct {
+	t        *testing.T
+	listener net.Listener
+	busy     bool
+	params   map[string]any
+	client   map[string]any
+	// Reset the first data connection of a reverse test partway through
+	dropStream bool
+}
+
+func newStandInServer(t *testing.T) *standInServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	return &standInServer{t: t, listener: l}
+}
+
+func (s *standInServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func writeJSON(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(data)))
+
+	_, err = w.Write(append(size, data...))
+
+	return err
+}
+
+func readJSON(r io.Reader, v any) error {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(size))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// serve handles a single test and reports protocol violations through t.Errorf.
+func (s *standInServer) serve() {
+	ctrl, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { _ = ctrl.Close() }()
+
+	cookie := make([]byte, 37)
+	if _, err := io.ReadFull(ctrl, cookie); err != nil {
+		s.t.Errorf("Failed to read cookie: %v", err)
+		return
+	}
+
+	if s.busy {
+		_, _ = ctrl.Write([]byte{0xff})
+		return
+	}
+
+	_, _ = ctrl.Write([]byte{9})
+	if err := readJSON(ctrl, &s.params); err != nil {
+		s.t.Errorf("Failed to read params: %v", err)
+		return
+	}
+
+	parallel := int(s.params["parallel"].(float64))
+	reverse, _ := s.params["reverse"].(bool)
+
+	_, _ = ctrl.Write([]byte{10})
+
+	streams := make([]net.Conn, parallel)
+	counts := make([]atomic.Int64, parallel)
+
+	for i := range streams {
+		streams[i], err = s.listener.Accept()
+		if err != nil {
+			s.t.Errorf("Failed to accept stream: %v", err)
+			return
+		}
+
+		streamCookie := make([]byte, 37)
+		if _, err := io.ReadFull(streams[i], streamCookie); err != nil || string(streamCookie) != string(cookie) {
+			s.t.Errorf("Stream cookie mismatch: %q != %q", streamCookie, cookie)
+			return
+		}
+	}
+
+	_, _ = ctrl.Write([]byte{1, 2})
+
+	var wg sync.WaitGroup
+
+	for i, conn := range streams {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			buf := make([]byte, 64*1024)
+			for {
+				var (
+					n   int
+					err error
+				)
+
+				if reverse {
+					n, err = conn.Write(buf)
+				} else {
+					n, err = conn.Read(buf)
+				}
+
+				counts[i].Add(int64(n))
+
+				if err != nil {
+					return
+				}
+
+				if s.dropStream && i == 0 && counts[i].Load() >= 256*1024 {
+					_ = conn.(*net.TCPConn).SetLinger(0)
+					_ = conn.Close()
+
+					return
+				}
+			}
+		}()
+	}
+
+	state := make([]byte, 1)
+	if _, err := io.ReadFull(ctrl, state); err != nil || state[0] != 4 {
+		s.t.Errorf("Expected TEST_END, got %v (%v)", state, err)
+		return
+	}
+
+	for _, conn := range streams {
+		_ = conn.Close()
+	}
+
+	wg.Wait()
+
+	_, _ = ctrl.Write([]byte{13})
+
+	// The client gives up on the test once it noticed the dropped stream
+	if s.dropStream {
+		return
+	}
+
+	if err := readJSON(ctrl, &s.client); err != nil {
+		s.t.Errorf("Failed to read client results: %v", err)
+		return
+	}
+
+	results := map[string]any{"cpu_util_total": 1, "cpu_util_user": 1, "cpu_util_system": 0, "sender_has_retransmits": 0}
+	var serverStreams []map[string]any
+
+	for i := range streams {
+		id := 1
+		if i > 0 {
+			id = i + 2
+		}
+
+		serverStreams = append(serverStreams, map[string]any{
+			"id": id, "bytes": counts[i].Load(), "retransmits": 0, "jitter": 0, "errors": 0,
+			"packets": 0, "start_time": 0, "end_time": 1,
+		})
+	}
+
+	results["streams"] = serverStreams
+	if err := writeJSON(ctrl, results); err != nil {
+		s.t.Errorf("Failed to write results: %v", err)
+		return
+	}
+
+	_, _ = ctrl.Write([]byte{14})
+	if _, err := io.ReadFull(ctrl, state); err != nil || state[0] != 16 {
+		s.t.Errorf("Expected IPERF_DONE, got %v (%v)", state, err)
+	}
+}
+
+// TestNativeRunner runs the native runner against an in-process stand-in server.
+func TestNativeRunner(t *testing.T) {
+	for _, reverse := range []bool{false, true} {
+		server := newStandInServer(t)
+		go server.serve()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		result := iperf.NewNativeRunner(slog.Default()).Run(ctx, iperf.Config{
+			Target:      "127.0.0.1",
+			Port:        server.port(),
+			Period:      time.Second,
+			Timeout:     10 * time.Second,
+			Protocol:    "tcp",
+			Parallel:    2,
+			ReverseMode: reverse,
+			Logger:      slog.Default(),
+		})
+
+		if !result.Success {
+			t.Fatalf("Expected success (reverse=%v), got error: %v", reverse, result.Error)
+		}
+
+		if result.SentBytes <= 0 || result.ReceivedBytes <= 0 {
+			t.Errorf("Expected bytes in both directions (reverse=%v), got sent=%v received=%v", reverse, result.SentBytes, result.ReceivedBytes)
+		}
+
+		if len(result.Streams) != 2 {
+			t.Errorf("Expected 2 streams, got %d", len(result.Streams))
+		}
+
+		if got := server.params["parallel"]; got != float64(2) {
+			t.Errorf("Expected parallel=2 in params, got %v", got)
+		}
+
+		if streams, _ := server.client["streams"].([]any); len(streams) != 2 {
+			t.Errorf("Expected 2 streams in client results, got %v", server.client["streams"])
+		}
+	}
+}
+
+// TestNativeRunnerServerBusy verifies that a busy server is classified as such.
+func TestNativeRunnerServerBusy(t *testing.T) {
+	server := newStandInServer(t)
+	server.busy = true
+
+	go server.serve()
+
+	result := iperf.NewNativeRunner(slog.Default()).Run(context.Background(), iperf.Config{
+		Target:   "127.0.0.1",
+		Port:     server.port(),
+		Period:   time.Second,
+		Protocol: "tcp",
+		Logger:   slog.Default(),
+	})
+
+	if result.Success {
+		t.Fatal("Expected failure against a busy server")
+	}
+
+	if result.FailureReason != iperf.FailureServerBusy {
+		t.Errorf("Expected reason %q, got %q", iperf.FailureServerBusy, result.FailureReason)
+	}
+}
+
+// TestNativeRunnerOmitsRetransmits verifies that retransmits, which the native runner does
+// not measure, are not exported as zeros.
+func TestNativeRunnerOmitsRetransmits(t *testing.T) {
+	server := newStandInServer(t)
+	go server.serve()
+
+	cfg := collector.TargetConfig{
+		Target:   "127.0.0.1",
+		Port:     server.port(),
+		Period:   time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "tcp",
+		Parallel: 2,
+		Runner:   iperf.RunnerNative,
+	}
+
+	body := scrapeCollector(t, cfg, iperf.NewNativeRunner(slog.Default()))
+
+	expectMetrics(t, body, `iperf3_up\{.*\} 1`)
+
+	if strings.Contains(body, "iperf3_retransmits{") || strings.Contains(body, "iperf3_stream_retransmits{") {
+		t.Error("Expected no retransmits from the native runner")
+	}
+}
+
+// TestNativeRunnerReverseStreamDropped verifies that a data connection reset by the server
+// in the middle of a reverse test fails the test instead of lowering its bitrate.
+func TestNativeRunnerReverseStreamDropped(t *testing.T) {
+	server := newStandInServer(t)
+	server.dropStream = true
+
+	go server.serve()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result := iperf.NewNativeRunner(slog.Default()).Run(ctx, iperf.Config{
+		Target:      "127.0.0.1",
+		Port:        server.port(),
+		Period:      time.Second,
+		Timeout:     10 * time.Second,
+		Protocol:    "tcp",
+		Parallel:    2,
+		ReverseMode: true,
+		Logger:      slog.Default(),
+	})
+
+	if result.Success {
+		t.Fatalf("Expected the dropped stream to fail the test, got %+v", result)
+	}
+
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "stream socket") {
+		t.Errorf("Expected a stream socket error, got %v", result.Error)
+	}
+}
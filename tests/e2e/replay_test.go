@@ -0,0 +1,285 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// TestReplayRunner verifies that the replay runner steps through the recordings of
+// a directory, including files with several documents, and parses them like live runs.
+func TestReplayRunner(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"01-client.json": `{
+  "start": {"test_start": {"protocol": "TCP", "reverse": 0}},
+  "intervals": [],
+  "end": {
+    "sum_sent": {"seconds": 10, "bytes": 1250000, "bits_per_second": 1000000, "retransmits": 3},
+    "sum_received": {"seconds": 10, "bytes": 1250000, "bits_per_second": 1000000}
+  }
+}`,
+		"02-server.json": `{"start": {"test_start": {"protocol": "UDP"}}, "intervals": [], "end": {"sum": {"seconds": 5, "bytes": 625000, "bits_per_second": 1000000, "jitter_ms": 0.5, "lost_packets": 2, "packets": 100, "lost_percent": 2}}}
+{"error": "the client has unexpectedly closed the connection"}
+`,
+		"notes.txt": "not a recording",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write recording: %v", err)
+		}
+	}
+
+	runner := iperf.NewReplayRunner(dir, slog.Default())
+	cfg := iperf.Config{
+		Target:   "replay.example.com",
+		Port:     5201,
+		Period:   time.Second,
+		Protocol: "tcp",
+		Logger:   slog.Default(),
+	}
+
+	tcp := runner.Run(context.Background(), cfg)
+	if !tcp.Success || tcp.Protocol != "tcp" || tcp.Retransmits != 3 || tcp.SentBytes != 1250000 {
+		t.Errorf("Unexpected first replayed result: %+v", tcp)
+	}
+
+	udp := runner.Run(context.Background(), cfg)
+	if !udp.Success || udp.Protocol != "udp" {
+		t.Errorf("Expected the second recording to be replayed as UDP, got %+v", udp)
+	}
+
+	failed := runner.Run(context.Background(), cfg)
+	if failed.Success || failed.Error == nil {
+		t.Errorf("Expected the recorded error to fail the run, got %+v", failed)
+	}
+
+	// The recordings start over after the last one
+	if again := runner.Run(context.Background(), cfg); again.SentBytes != tcp.SentBytes {
+		t.Errorf("Expected the replay to start over, got %+v", again)
+	}
+}
+
+// TestReplayBidirStreams verifies that only the client to server streams of a bidirectional
+// test are reported as its streams.
+func TestReplayBidirStreams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bidir.json")
+
+	recording := `{
+  "start": {"test_start": {"protocol": "TCP", "num_streams": 2, "bidir": 1}},
+  "intervals": [],
+  "end": {
+    "streams": [
+      {"sender": {"socket": 5, "bytes": 1000, "bits_per_second": 800, "retransmits": 1, "sender": true}, "receiver": {"socket": 5, "bytes": 1000, "bits_per_second": 800, "sender": false}},
+      {"sender": {"socket": 7, "bytes": 3000, "bits_per_second": 2400, "retransmits": 0, "sender": true}, "receiver": {"socket": 7, "bytes": 3000, "bits_per_second": 2400, "sender": false}},
+      {"sender": {"socket": 9, "bytes": 5000, "bits_per_second": 4000, "sender": false}, "receiver": {"socket": 9, "bytes": 5000, "bits_per_second": 4000, "sender": true}}
+    ],
+    "sum_sent": {"seconds": 10, "bytes": 4000, "bits_per_second": 3200, "retransmits": 1},
+    "sum_received": {"seconds": 10, "bytes": 4000, "bits_per_second": 3200},
+    "sum_sent_bidir_reverse": {"seconds": 10, "bytes": 5000, "bits_per_second": 4000},
+    "sum_received_bidir_reverse": {"seconds": 10, "bytes": 5000, "bits_per_second": 4000}
+  }
+}`
+
+	if err := os.WriteFile(path, []byte(recording), 0o600); err != nil {
+		t.Fatalf("Failed to write recording: %v", err)
+	}
+
+	result := iperf.NewReplayRunner(path, slog.Default()).Run(context.Background(), iperf.Config{
+		Target:   "replay.example.com",
+		Port:     5201,
+		Period:   time.Second,
+		Protocol: "tcp",
+		Logger:   slog.Default(),
+	})
+
+	if !result.Success {
+		t.Fatalf("Expected the recording to be replayed, got %+v", result)
+	}
+
+	if len(result.Streams) != 2 {
+		t.Fatalf("Expected the 2 client to server streams, got %+v", result.Streams)
+	}
+
+	for _, stream := range result.Streams {
+		if stream.Socket == 9 {
+			t.Errorf("Expected the server to client stream to be skipped, got %+v", stream)
+		}
+	}
+}
+
+// TestReplaySCTP verifies that a recorded SCTP test is parsed like the stream oriented TCP
+// tests, without the TCP only metrics.
+func TestReplaySCTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sctp.json")
+
+	recording := `{
+  "start": {"test_start": {"protocol": "SCTP", "num_streams": 2, "reverse": 0}},
+  "intervals": [],
+  "end": {
+    "streams": [
+      {"sender": {"socket": 5, "bytes": 2000, "bits_per_second": 1600, "sender": true}, "receiver": {"socket": 5, "bytes": 1900, "bits_per_second": 1520, "sender": false}},
+      {"sender": {"socket": 7, "bytes": 3000, "bits_per_second": 2400, "sender": true}, "receiver": {"socket": 7, "bytes": 2900, "bits_per_second": 2320, "sender": false}}
+    ],
+    "sum_sent": {"seconds": 10, "bytes": 5000, "bits_per_second": 4000},
+    "sum_received": {"seconds": 10, "bytes": 4800, "bits_per_second": 3840}
+  }
+}`
+
+	if err := os.WriteFile(path, []byte(recording), 0o600); err != nil {
+		t.Fatalf("Failed to write recording: %v", err)
+	}
+
+	cfg := iperf.Config{
+		Target:   "replay.example.com",
+		Port:     5201,
+		Period:   time.Second,
+		Protocol: "tcp",
+		Logger:   slog.Default(),
+	}
+
+	result := iperf.NewReplayRunner(path, slog.Default()).Run(context.Background(), cfg)
+	if !result.Success || result.Protocol != "sctp" {
+		t.Fatalf("Expected the recording to be replayed as SCTP, got %+v", result)
+	}
+
+	if result.SentBytes != 5000 || result.ReceivedBytes != 4800 || result.ReceivedBitsPerSecond != 3840 {
+		t.Errorf("Expected the sums of the recording, got %+v", result)
+	}
+
+	if len(result.Streams) != 2 || result.Streams[1].ReceivedBytes != 2900 {
+		t.Errorf("Expected both streams with their receiver side, got %+v", result.Streams)
+	}
+
+	if result.TCPInfo != nil {
+		t.Errorf("Expected no TCP_INFO metrics for SCTP, got %+v", result.TCPInfo)
+	}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Name:     "sctp",
+		Target:   "replay.example.com",
+		Port:     5201,
+		Period:   time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "sctp",
+		Runner:   iperf.RunnerReplay,
+	}, iperf.NewReplayRunner(path, slog.Default()))
+
+	expectMetrics(t, body,
+		`iperf3_up\{name="sctp",port="5201",protocol="sctp",.*\} 1`,
+		`iperf3_received_bytes\{name="sctp",.*\} 4800`,
+		`iperf3_stream_received_bytes\{name="sctp",.*,stream="1",.*\} 2900`,
+	)
+
+	if strings.Contains(body, "iperf3_retransmits") {
+		t.Errorf("Expected no retransmits for SCTP, got:\n%s", body)
+	}
+}
+
+// TestReplayBidirUDP verifies that the client to server streams of a bidirectional UDP test
+// make up its results, and that both directions are exported.
+func TestReplayBidirUDP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bidir-udp.json")
+
+	recording := `{
+  "start": {"test_start": {"protocol": "UDP", "num_streams": 2, "bidir": 1}},
+  "intervals": [],
+  "end": {
+    "streams": [
+      {"udp": {"socket": 5, "seconds": 10, "bytes": 1000, "bits_per_second": 800, "jitter_ms": 0.2, "lost_packets": 2, "packets": 100, "lost_percent": 2, "sender": true}},
+      {"udp": {"socket": 7, "seconds": 10, "bytes": 3000, "bits_per_second": 2400, "jitter_ms": 0.4, "lost_packets": 6, "packets": 300, "lost_percent": 2, "sender": true}},
+      {"udp": {"socket": 9, "seconds": 10, "bytes": 5000, "bits_per_second": 4000, "jitter_ms": 1.5, "lost_packets": 250, "packets": 500, "lost_percent": 50, "sender": false}},
+      {"udp": {"socket": 11, "seconds": 10, "bytes": 5000, "bits_per_second": 4000, "jitter_ms": 1.5, "lost_packets": 250, "packets": 500, "lost_percent": 50, "sender": false}}
+    ],
+    "sum": {"seconds": 10, "bytes": 3920, "bits_per_second": 3136, "jitter_ms": 0.3, "lost_packets": 8, "packets": 400, "lost_percent": 2},
+    "sum_sent_bidir_reverse": {"seconds": 10, "bytes": 10000, "bits_per_second": 8000},
+    "sum_received_bidir_reverse": {"seconds": 10, "bytes": 5000, "bits_per_second": 4000}
+  }
+}`
+
+	if err := os.WriteFile(path, []byte(recording), 0o600); err != nil {
+		t.Fatalf("Failed to write recording: %v", err)
+	}
+
+	result := iperf.NewReplayRunner(path, slog.Default()).Run(context.Background(), iperf.Config{
+		Target:   "replay.example.com",
+		Port:     5201,
+		Period:   time.Second,
+		Protocol: "udp",
+		Logger:   slog.Default(),
+	})
+
+	if !result.Success {
+		t.Fatalf("Expected the recording to be replayed, got %+v", result)
+	}
+
+	if len(result.Streams) != 2 {
+		t.Fatalf("Expected the 2 client to server streams, got %+v", result.Streams)
+	}
+
+	if result.SentBytes != 4000 || result.SentPackets != 400 || result.SentLostPackets != 8 {
+		t.Errorf("Expected the sums of the client to server streams, got %+v", result)
+	}
+
+	if result.SentLostPercent != 2 {
+		t.Errorf("Expected a sent lost percent of 2, got %v", result.SentLostPercent)
+	}
+
+	if result.SentJitter < 0.29 || result.SentJitter > 0.31 {
+		t.Errorf("Expected the mean jitter of the client to server streams, got %v", result.SentJitter)
+	}
+
+	if result.ReceivedBytes != 3920 || result.ReceivedLostPercent != 2 {
+		t.Errorf("Expected the received sums of the client to server direction, got %+v", result)
+	}
+
+	if result.BidirReverse == nil || result.BidirReverse.SentBytes != 10000 || result.BidirReverse.ReceivedBytes != 5000 {
+		t.Fatalf("Expected the sums of the server to client direction, got %+v", result.BidirReverse)
+	}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Name:     "bidir-udp",
+		Target:   "replay.example.com",
+		Port:     5201,
+		Period:   time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "udp",
+		Bidir:    true,
+		Runner:   iperf.RunnerReplay,
+	}, iperf.NewReplayRunner(path, slog.Default()))
+
+	expectMetrics(t, body,
+		`iperf3_bidir_sent_bytes\{direction="forward",name="bidir-udp",.*\} 4000\n`,
+		`iperf3_bidir_received_bytes\{direction="forward",name="bidir-udp",.*\} 3920\n`,
+		`iperf3_bidir_sent_bytes\{direction="reverse",name="bidir-udp",.*\} 10000\n`,
+		`iperf3_bidir_received_bytes\{direction="reverse",name="bidir-udp",.*\} 5000\n`,
+		`iperf3_bidir_received_bits_per_second\{direction="reverse",name="bidir-udp",.*\} 4000\n`,
+		`iperf3_sent_lost_percent\{name="bidir-udp",.*\} 2\n`,
+	)
+
+	if strings.Contains(body, "iperf3_bidir_retransmits") {
+		t.Errorf("Expected no retransmits for UDP, got:\n%s", body)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/scheduler"
+)
+
+// TestSchedulerDestination verifies that only one test runs against a destination at a
+// time, while tests against other destinations are not held up.
+func TestSchedulerDestination(t *testing.T) {
+	s := scheduler.New(0)
+	dest := scheduler.Destination("10.0.0.1", 5201)
+
+	release, err := s.Acquire(context.Background(), dest, scheduler.SourceTarget)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := s.Acquire(ctx, dest, scheduler.SourceProbe); err == nil {
+		t.Error("Expected a second test against the same destination to wait")
+	}
+
+	other, err := s.Acquire(context.Background(), scheduler.Destination("10.0.0.2", 5201), scheduler.SourceTarget)
+	if err != nil {
+		t.Fatalf("Expected a test against another destination to run, got error = %v", err)
+	}
+	other()
+
+	acquired := make(chan func())
+	go func() {
+		next, err := s.Acquire(context.Background(), dest, scheduler.SourceProbe)
+		if err != nil {
+			t.Errorf("Acquire() error = %v", err)
+			close(acquired)
+			return
+		}
+		acquired <- next
+	}()
+
+	release()
+
+	select {
+	case next := <-acquired:
+		if next != nil {
+			next()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the waiting test to run once the previous test finished")
+	}
+}
+
+// TestSchedulerConcurrencyLimit verifies the global limit of tests running at the same time.
+func TestSchedulerConcurrencyLimit(t *testing.T) {
+	s := scheduler.New(2)
+
+	first, err := s.Acquire(context.Background(), scheduler.Destination("10.0.0.1", 5201), scheduler.SourceTarget)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer first()
+
+	second, err := s.Acquire(context.Background(), scheduler.Destination("10.0.0.2", 5201), scheduler.SourceTarget)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := s.Acquire(ctx, scheduler.Destination("10.0.0.3", 5201), scheduler.SourceProbe); err == nil {
+		t.Error("Expected a third test to wait for a free slot")
+	}
+
+	second()
+
+	third, err := s.Acquire(context.Background(), scheduler.Destination("10.0.0.3", 5201), scheduler.SourceProbe)
+	if err != nil {
+		t.Fatalf("Expected the third test to run once a slot was freed, got error = %v", err)
+	}
+	third()
+}
+
+// TestSplay verifies that the splay of a target is stable and within its interval.
+func TestSplay(t *testing.T) {
+	interval := time.Hour
+
+	offsets := make(map[time.Duration]bool)
+	for _, name := range []string{"dc1", "dc2", "dc3", "dc4"} {
+		splay := scheduler.Splay(name, interval)
+		if splay < 0 || splay >= interval {
+			t.Errorf("Splay(%q) = %v, want within [0, %v)", name, splay, interval)
+		}
+		if again := scheduler.Splay(name, interval); again != splay {
+			t.Errorf("Splay(%q) = %v, then %v", name, splay, again)
+		}
+		offsets[splay] = true
+	}
+
+	if len(offsets) < 2 {
+		t.Error("Expected targets to be spread over the interval")
+	}
+}
+
+// TestFirstRun verifies that a target resumes its cadence after a restart and otherwise
+// starts after its splay.
+func TestFirstRun(t *testing.T) {
+	interval := time.Hour
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	splayed := now.Add(scheduler.Splay("dc1", interval))
+
+	tests := []struct {
+		name    string
+		lastRun time.Time
+		want    time.Time
+	}{
+		{name: "never ran", want: splayed},
+		{name: "ran within the interval", lastRun: now.Add(-20 * time.Minute), want: now.Add(40 * time.Minute)},
+		{name: "missed a run", lastRun: now.Add(-3 * time.Hour), want: splayed},
+		{name: "ran in the future", lastRun: now.Add(2 * time.Hour), want: splayed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scheduler.FirstRun("dc1", interval, tt.lastRun, now); !got.Equal(tt.want) {
+				t.Errorf("FirstRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestState verifies that the last runs of the targets survive a restart.
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	lastRun := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	state := scheduler.NewState(path)
+	if err := state.Load(); err != nil {
+		t.Fatalf("Load() of a missing state file error = %v", err)
+	}
+
+	if err := state.Record("dc1", lastRun); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	restarted := scheduler.NewState(path)
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got := restarted.LastRun("dc1"); !got.Equal(lastRun) {
+		t.Errorf("LastRun(dc1) = %v, want %v", got, lastRun)
+	}
+	if got := restarted.LastRun("dc2"); !got.IsZero() {
+		t.Errorf("LastRun(dc2) = %v, want the zero time", got)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.NewState(path).Load(); err == nil {
+		t.Error("Expected an error loading a corrupt state file")
+	}
+}
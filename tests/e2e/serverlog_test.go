@@ -0,0 +1,114 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+const serverLogTCP = `{
+	"start": {
+		"connected": [{"socket": 5, "local_host": "10.0.0.1", "local_port": 5201, "remote_host": "10.0.0.2", "remote_port": 40000}],
+		"accepted_connection": {"host": "10.0.0.2", "port": 39998},
+		"timestamp": {"time": "Sat, 17 Oct 2026 10:00:00 GMT", "timesecs": 1792231200},
+		"test_start": {"protocol": "TCP", "num_streams": 1, "reverse": 0, "bidir": 0}
+	},
+	"end": {
+		"sum_sent": {"seconds": 5, "bytes": 1000, "bits_per_second": 1600},
+		"sum_received": {"seconds": 5, "bytes": 900, "bits_per_second": 1440}
+	}
+}
+`
+
+const serverLogUDP = `{
+	"start": {
+		"connected": [{"socket": 5, "local_host": "10.0.0.1", "local_port": 5201, "remote_host": "10.0.0.3", "remote_port": 40000}],
+		"accepted_connection": {"host": "10.0.0.3", "port": 39998},
+		"timestamp": {"time": "Sat, 17 Oct 2026 10:01:00 GMT", "timesecs": 1792231260},
+		"test_start": {"protocol": "UDP", "num_streams": 1, "reverse": 0, "bidir": 0}
+	},
+	"end": {
+		"streams": [{"udp": {"socket": 5, "seconds": 5, "bytes": 500, "bits_per_second": 800, "jitter_ms": 0.5, "lost_packets": 2, "packets": 100, "lost_percent": 2, "sender": false}}],
+		"sum": {"seconds": 5, "bytes": 500, "bits_per_second": 800, "jitter_ms": 0.5, "lost_packets": 2, "packets": 100, "lost_percent": 2, "sender": false}
+	}
+}
+`
+
+// TestServerLogTailer verifies that tests appended to a server JSON log, including
+// a partially written one, are ingested and exported per client.
+func TestServerLogTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iperf3.json")
+
+	// The second object is only partially written at first
+	split := len(serverLogUDP) / 2
+	if err := os.WriteFile(path, []byte(serverLogTCP+serverLogUDP[:split]), 0o600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan iperf.ServerResult, 2)
+	tailer := iperf.NewServerLogTailer(path, 10*time.Millisecond, slog.Default())
+
+	go tailer.Run(ctx, func(result iperf.ServerResult) { results <- result })
+
+	c := collector.NewServerLogCollector()
+	next := func() iperf.ServerResult {
+		select {
+		case result := <-results:
+			c.Record(result)
+			return result
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a server log entry")
+			return iperf.ServerResult{}
+		}
+	}
+
+	if result := next(); result.Client != "10.0.0.2" || result.Protocol != "tcp" {
+		t.Errorf("Unexpected first result: client=%q protocol=%q", result.Client, result.Protocol)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+
+	if _, err := f.WriteString(serverLogUDP[split:]); err != nil {
+		t.Fatalf("Failed to append to log: %v", err)
+	}
+
+	_ = f.Close()
+
+	if result := next(); result.Client != "10.0.0.3" || result.Protocol != "udp" {
+		t.Errorf("Unexpected second result: client=%q protocol=%q", result.Client, result.Protocol)
+	}
+
+	body := scrape(t, c)
+	expectMetrics(t, body,
+		`iperf3_server_client_received_bits_per_second{client="10.0.0.2",port="5201",protocol="tcp",reverse="false"} 1440`,
+		`iperf3_server_client_tests_total{client="10.0.0.2",port="5201",protocol="tcp",reverse="false"} 1`,
+		`iperf3_server_client_received_jitter_ms{client="10.0.0.3",port="5201",protocol="udp",reverse="false"} 0.5`,
+		`iperf3_server_client_received_lost_percent{client="10.0.0.3",port="5201",protocol="udp",reverse="false"} 2`,
+		`iperf3_server_client_last_test_timestamp_seconds{client="10.0.0.3",port="5201",protocol="udp",reverse="false"} 1.79223126e\+09`,
+	)
+}
@@ -0,0 +1,71 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// fakeServerOutputIperf3 prints a reverse TCP test whose server output has not been summarized yet,
+// as iperf3 sends it before the server computes its totals.
+const fakeServerOutputIperf3 = `#!/bin/sh
+case "$*" in *--get-server-output*) ;; *) echo "missing --get-server-output" >&2; exit 1 ;; esac
+cat <<'JSON'
+{
+  "start": {"test_start": {"protocol": "TCP", "reverse": 1}},
+  "intervals": [],
+  "end": {
+    "sum_sent": {"seconds": 2, "bytes": 500, "bits_per_second": 2000},
+    "sum_received": {"seconds": 2, "bytes": 500, "bits_per_second": 2000},
+    "cpu_utilization_percent": {"host_total": 10, "host_user": 4, "host_system": 6, "remote_total": 20, "remote_user": 5, "remote_system": 15}
+  },
+  "server_output_json": {
+    "intervals": [
+      {"sum": {"start": 0, "end": 1, "seconds": 1, "bytes": 200, "bits_per_second": 1600}},
+      {"sum": {"start": 1, "end": 2, "seconds": 1, "bytes": 300, "bits_per_second": 2400}}
+    ],
+    "end": {}
+  }
+}
+JSON
+`
+
+// TestServerOutputMetrics verifies that the server output is totalled from its intervals
+// and exported with side="server", taking the server CPU utilization from the client's view.
+func TestServerOutputMetrics(t *testing.T) {
+	binary := writeFakeIperf3(t, fakeServerOutputIperf3)
+
+	body := scrape(t, collector.NewCollector(collector.TargetConfig{
+		Target:          "127.0.0.1",
+		Port:            5201,
+		Period:          2 * time.Second,
+		Timeout:         10 * time.Second,
+		Protocol:        "tcp",
+		ReverseMode:     true,
+		GetServerOutput: true,
+		Runner:          iperf.RunnerExec,
+		Binary:          binary,
+	}, slog.Default()))
+
+	expectMetrics(t, body,
+		`iperf3_server_output_sent_bytes\{.*reverse="true",side="server",target="127.0.0.1"\} 500`,
+		`iperf3_server_output_sent_bits_per_second\{.*side="server".*\} 2000`,
+		`iperf3_server_output_cpu_utilization_percent\{mode="system",.*side="server".*\} 15`,
+	)
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// fakeStreamingIperf3 prints the --json-stream output of a two interval TCP test.
+const fakeStreamingIperf3 = `#!/bin/sh
+case "$*" in *--json-stream*) ;; *) echo "missing --json-stream" >&2; exit 1 ;; esac
+echo '{"event":"start","data":{"test_start":{"protocol":"TCP"}}}'
+echo '{"event":"interval","data":{"streams":[{"rtt":1000}],"sum":{"start":0,"end":1,"seconds":1,"bytes":125,"bits_per_second":1000}}}'
+echo 'warning: not an event'
+echo '{"event":"interval","data":{"streams":[{"rtt":3000}],"sum":{"start":1,"end":2,"seconds":1,"bytes":250,"bits_per_second":2000}}}'
+echo '{"event":"end","data":{"sum_sent":{"seconds":2,"bytes":375,"bits_per_second":1500,"retransmits":1},"sum_received":{"seconds":2,"bytes":375,"bits_per_second":1500}}}'
+`
+
+// writeFakeIperf3 writes an executable shell script standing in for the iperf3 binary.
+func writeFakeIperf3(t *testing.T, script string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script stand-ins for iperf3 are not supported on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "iperf3")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake iperf3: %v", err)
+	}
+
+	return path
+}
+
+// TestStreamingRunner verifies that intervals are delivered while the test runs
+// and that the streamed events are parsed into the same result as -J output.
+func TestStreamingRunner(t *testing.T) {
+	binary := writeFakeIperf3(t, fakeStreamingIperf3)
+
+	var intervals []iperf.Interval
+
+	result := iperf.NewRunner(slog.Default()).Run(context.Background(), iperf.Config{
+		Target:     "127.0.0.1",
+		Port:       5201,
+		Period:     2 * time.Second,
+		Timeout:    10 * time.Second,
+		Protocol:   "tcp",
+		Binary:     binary,
+		OnInterval: func(interval iperf.Interval) { intervals = append(intervals, interval) },
+		Logger:     slog.Default(),
+	})
+
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.Error)
+	}
+
+	if len(intervals) != 2 || intervals[1].BitsPerSecond != 2000 || intervals[1].RTT != 3*time.Millisecond {
+		t.Errorf("Unexpected streamed intervals: %+v", intervals)
+	}
+
+	if len(result.Intervals) != 2 || result.SentBitsPerSecond != 1500 || result.Retransmits != 1 {
+		t.Errorf("Unexpected result: intervals=%d sent_bps=%v retransmits=%v", len(result.Intervals), result.SentBitsPerSecond, result.Retransmits)
+	}
+}
+
+// TestStreamingRunnerError verifies that an error event fails the test with its message.
+func TestStreamingRunnerError(t *testing.T) {
+	binary := writeFakeIperf3(t, `#!/bin/sh
+echo '{"event":"error","data":"unable to connect to server: Connection refused"}'
+exit 1
+`)
+
+	result := iperf.NewRunner(slog.Default()).Run(context.Background(), iperf.Config{
+		Target:     "127.0.0.1",
+		Port:       5201,
+		Period:     time.Second,
+		Protocol:   "tcp",
+		Binary:     binary,
+		OnInterval: func(iperf.Interval) {},
+		Logger:     slog.Default(),
+	})
+
+	if result.Success || result.FailureReason != iperf.FailureConnectionRefused {
+		t.Errorf("Expected a connection_refused failure, got success=%v reason=%q", result.Success, result.FailureReason)
+	}
+
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "Connection refused") {
+		t.Errorf("Expected the iperf3 error message in the result error, got %v", result.Error)
+	}
+}
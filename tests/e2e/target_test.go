@@ -0,0 +1,235 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/config"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+	"github.com/yuvaldekel/iperf3_exporter/internal/scheduler"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestTargetLabels verifies that the name and the custom labels of a target are added to its metrics.
+func TestTargetLabels(t *testing.T) {
+	body := scrapeCollector(t, collector.TargetConfig{
+		Name:     "fra-ams-backbone",
+		Target:   "10.0.0.1",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "tcp",
+		Labels:   map[string]string{"site": "fra1", "link_id": "L-1042"},
+	}, &MockRunner{Result: iperf.Result{Success: true, Protocol: "tcp", SentBitsPerSecond: 1e9}})
+
+	expectMetrics(t, body,
+		`iperf3_up\{link_id="L-1042",name="fra-ams-backbone",port="5201",protocol="tcp",reverse="false",site="fra1",target="10.0.0.1"\} 1`,
+		`iperf3_sent_bits_per_second\{link_id="L-1042",name="fra-ams-backbone",.*\} 1e\+09`,
+	)
+
+	current := collector.CurrentMetrics(collector.TargetConfig{
+		Name:     "fra-ams-backbone",
+		Target:   "10.0.0.1",
+		Port:     5201,
+		Protocol: "tcp",
+		Labels:   map[string]string{"site": "fra1"},
+	}, iperf.Interval{End: 1, BitsPerSecond: 1e9})
+
+	for _, family := range current {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+
+			if labels["name"] != "fra-ams-backbone" || labels["site"] != "fra1" {
+				t.Errorf("Expected the target labels on %s, got %v", family.GetName(), labels)
+			}
+		}
+	}
+}
+
+// TestTargetFailureLabels verifies that the failure and skipped test metrics of a target carry its custom labels.
+func TestTargetFailureLabels(t *testing.T) {
+	cfg := collector.TargetConfig{
+		Name:     "failing-link",
+		Target:   "10.0.0.9",
+		Port:     5201,
+		Period:   5 * time.Second,
+		Timeout:  10 * time.Second,
+		Protocol: "tcp",
+		Labels:   map[string]string{"site": "fra1"},
+	}
+
+	c := collector.NewCollectorWithRunner(cfg, slog.Default(), &MockRunner{Result: iperf.Result{
+		Success:       false,
+		FailureReason: iperf.FailureConnectionRefused,
+	}})
+	c.Run()
+	c.Run()
+
+	scheduler.SkippedTests.Inc(cfg.Name, cfg.ConstLabels())
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector.Failures, scheduler.SkippedTests)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	for _, name := range []string{"iperf3_probe_failures_total", "iperf3_last_failure_info", "iperf3_scheduler_skipped_tests_total"} {
+		family := findFamily(families, name)
+		if family == nil {
+			t.Errorf("Expected %s to be exported", name)
+			continue
+		}
+
+		found := false
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+
+			if labels["name"] != "failing-link" {
+				continue
+			}
+
+			found = true
+			if labels["site"] != "fra1" {
+				t.Errorf("Expected the custom labels on %s, got %v", name, labels)
+			}
+			if name == "iperf3_probe_failures_total" && metric.GetCounter().GetValue() != 2 {
+				t.Errorf("Expected 2 failures, got %v", metric.GetCounter().GetValue())
+			}
+		}
+
+		if !found {
+			t.Errorf("Expected %s of the target", name)
+		}
+	}
+}
+
+// validConfig returns a configuration that passes validation with the given targets.
+func validConfig(targets ...collector.TargetConfig) *config.Config {
+	return &config.Config{
+		MetricsPath:   "/metrics",
+		ProbePath:     "/probe",
+		Timeout:       30 * time.Second,
+		Logger:        slog.Default(),
+		Runner:        iperf.RunnerNative,
+		OverlapPolicy: scheduler.OverlapSkip,
+		Targets:       targets,
+	}
+}
+
+// TestTargetIdentity verifies that targets need a unique name and valid custom labels.
+func TestTargetIdentity(t *testing.T) {
+	target := collector.TargetConfig{
+		Name:     "a",
+		Target:   "10.0.0.1",
+		Port:     5201,
+		Protocol: "tcp",
+		Runner:   iperf.RunnerNative,
+	}
+
+	other := target
+	other.Name = "b"
+	other.Bitrate = "10M"
+
+	if err := validConfig(target, other).Validate(); err != nil {
+		t.Errorf("Expected targets with unique names to be valid, got %v", err)
+	}
+
+	other.Name = "a"
+	if err := validConfig(target, other).Validate(); err == nil || !strings.Contains(err.Error(), "duplicate target name") {
+		t.Errorf("Expected duplicate target names to be rejected, got %v", err)
+	}
+
+	other.Name = ""
+	if err := validConfig(target, other).Validate(); err == nil || !strings.Contains(err.Error(), "missing target name") {
+		t.Errorf("Expected a target without a name to be rejected, got %v", err)
+	}
+
+	for _, labels := range []map[string]string{
+		{"target": "override"},
+		{"link-id": "L-1042"},
+		{"__meta": "x"},
+	} {
+		target.Labels = labels
+		if err := validConfig(target).Validate(); err == nil {
+			t.Errorf("Expected labels %v to be rejected", labels)
+		}
+	}
+}
+
+// TestNativeTargetOptions verifies that targets of the native runner cannot use sctp or bidir.
+func TestNativeTargetOptions(t *testing.T) {
+	target := collector.TargetConfig{
+		Name:     "a",
+		Target:   "10.0.0.1",
+		Port:     5201,
+		Protocol: "udp",
+		Runner:   iperf.RunnerNative,
+	}
+
+	if err := validConfig(target).Validate(); err != nil {
+		t.Errorf("Expected a udp target of the native runner to be valid, got %v", err)
+	}
+
+	sctp := target
+	sctp.Protocol = "sctp"
+	if err := validConfig(sctp).Validate(); err == nil || !strings.Contains(err.Error(), "does not support protocol") {
+		t.Errorf("Expected a sctp target of the native runner to be rejected, got %v", err)
+	}
+
+	bidir := target
+	bidir.Bidir = true
+	if err := validConfig(bidir).Validate(); err == nil || !strings.Contains(err.Error(), "does not support 'bidir'") {
+		t.Errorf("Expected a bidir target of the native runner to be rejected, got %v", err)
+	}
+
+	if err := config.CheckNativeTarget(collector.TargetConfig{Protocol: "tcp"}); err != nil {
+		t.Errorf("Expected the options of a tcp probe to be accepted, got %v", err)
+	}
+}
+
+// TestSCTPTarget verifies that sctp is accepted for probes and targets of the exec runner.
+func TestSCTPTarget(t *testing.T) {
+	if !iperf.ValidateProtocol("sctp") {
+		t.Error("Expected the sctp protocol parameter to be accepted")
+	}
+
+	if iperf.ValidateProtocol("quic") {
+		t.Error("Expected an unknown protocol parameter to be rejected")
+	}
+
+	target := collector.TargetConfig{
+		Name:     "a",
+		Target:   "10.0.0.1",
+		Port:     5201,
+		Protocol: "sctp",
+		Runner:   iperf.RunnerExec,
+	}
+
+	if err := validConfig(target).Validate(); err != nil {
+		t.Errorf("Expected a sctp target of the exec runner to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Yuval Dekel
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuvaldekel/iperf3_exporter/internal/collector"
+	"github.com/yuvaldekel/iperf3_exporter/internal/iperf"
+)
+
+// fakeInterruptedIperf3 runs until it is interrupted and then prints the results measured
+// so far, like iperf3 does on SIGINT. It leaves a child behind in its process group.
+const fakeInterruptedIperf3 = `#!/bin/sh
+trap 'cat <<JSON
+{
+  "start": {"test_start": {"protocol": "TCP"}},
+  "intervals": [],
+  "end": {
+    "sum_sent": {"seconds": 1.5, "bytes": 1875000, "bits_per_second": 10000000, "retransmits": 2},
+    "sum_received": {"seconds": 1.5, "bytes": 1875000, "bits_per_second": 10000000}
+  },
+  "error": "interrupt - the client has terminated"
+}
+JSON
+exit 1' INT
+sleep 60 > /dev/null 2>&1 &
+echo $! > "$(dirname "$0")/child"
+wait
+`
+
+// fakeStuckIperf3 ignores SIGINT and never exits on its own.
+const fakeStuckIperf3 = `#!/bin/sh
+trap '' INT
+sleep 60
+`
+
+// processGone reports whether the process pid has exited.
+func processGone(t *testing.T, pid string) bool {
+	t.Helper()
+
+	stat, err := os.ReadFile(filepath.Join("/proc", pid, "stat"))
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		t.Skipf("Cannot inspect processes: %v", err)
+	}
+
+	// Exited but not yet reaped by its new parent
+	fields := strings.Fields(string(stat))
+
+	return len(fields) > 2 && fields[2] == "Z"
+}
+
+// TestTimeoutPartialResults verifies that iperf3 is interrupted before the deadline,
+// that its partial results are exported as truncated and that its children are killed.
+func TestTimeoutPartialResults(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("process inspection requires /proc")
+	}
+
+	binary := writeFakeIperf3(t, fakeInterruptedIperf3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	result := iperf.NewRunner(slog.Default()).Run(ctx, iperf.Config{
+		Target:      "127.0.0.1",
+		Port:        5201,
+		Period:      10 * time.Second,
+		Protocol:    "tcp",
+		Binary:      binary,
+		GracePeriod: time.Second,
+		Logger:      slog.Default(),
+	})
+
+	if elapsed := time.Since(start); elapsed >= 3*time.Second {
+		t.Errorf("Expected iperf3 to be interrupted before the deadline, took %v", elapsed)
+	}
+
+	if !result.Success || !result.Truncated {
+		t.Fatalf("Expected truncated successful result, got success=%v truncated=%v error=%v", result.Success, result.Truncated, result.Error)
+	}
+
+	if result.SentSeconds != 1.5 || result.SentBitsPerSecond != 10000000 || result.Retransmits != 2 {
+		t.Errorf("Unexpected partial results: %+v", result)
+	}
+
+	child, err := os.ReadFile(filepath.Join(filepath.Dir(binary), "child"))
+	if err != nil {
+		t.Fatalf("Failed to read the child PID: %v", err)
+	}
+
+	pid := strings.TrimSpace(string(child))
+	deadline := time.Now().Add(2 * time.Second)
+	for !processGone(t, pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the child %s of iperf3 to be killed", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	body := scrapeCollector(t, collector.TargetConfig{
+		Target:   "127.0.0.1",
+		Port:     5201,
+		Period:   10 * time.Second,
+		Timeout:  time.Second,
+		Protocol: "tcp",
+	}, &MockRunner{Result: result})
+
+	expectMetrics(t, body,
+		`iperf3_up{port="5201",protocol="tcp",reverse="false",target="127.0.0.1"} 1`,
+		`iperf3_result_truncated{port="5201",protocol="tcp",reverse="false",target="127.0.0.1"} 1`,
+	)
+}
+
+// TestTimeoutKillsStuckIperf3 verifies that an iperf3 process ignoring the interrupt is
+// killed once the grace period is over and the test fails with a timeout.
+func TestTimeoutKillsStuckIperf3(t *testing.T) {
+	binary := writeFakeIperf3(t, fakeStuckIperf3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	result := iperf.NewRunner(slog.Default()).Run(ctx, iperf.Config{
+		Target:      "127.0.0.1",
+		Port:        5201,
+		Period:      10 * time.Second,
+		Protocol:    "tcp",
+		Binary:      binary,
+		GracePeriod: 500 * time.Millisecond,
+		Logger:      slog.Default(),
+	})
+
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Expected the stuck process to be killed at the deadline, took %v", elapsed)
+	}
+
+	if result.Success || result.Truncated {
+		t.Fatalf("Expected the test to fail, got success=%v truncated=%v", result.Success, result.Truncated)
+	}
+
+	if result.FailureReason != iperf.FailureTimeout {
+		t.Errorf("Expected failure reason %q, got %q (error: %v)", iperf.FailureTimeout, result.FailureReason, result.Error)
+	}
+}